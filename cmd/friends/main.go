@@ -16,6 +16,7 @@ import (
 	"github.com/BethesdaNet/friends-go/internal/db/redis"
 	"github.com/BethesdaNet/friends-go/internal/friends"
 	"github.com/BethesdaNet/friends-go/internal/metric/relic"
+	"github.com/BethesdaNet/friends-go/internal/platform"
 	"github.com/BethesdaNet/friends-go/internal/provider"
 )
 
@@ -23,14 +24,28 @@ var (
 	name         = flag.String("name", "local-friends", "name of service")
 	env          = flag.String("env", "local", "env of service")
 	addr         = flag.String("addr", "localhost:10000", "address of the http listener")
+	private      = flag.Bool("private", false, "serve the private routes for other platform services")
+	serviceKey   = flag.String("serviceKey", "", "key platform services send to call private routes")
+	serverKey    = flag.String("serverKey", "", "key game servers send when no key set is configured")
 	redisAddr    = flag.String("redisAddr", "localhost:6379", "address of redis")
 	redisCluster = flag.Bool("redisCluster", true, "enable clustered redis agent")
 	identityAddr = flag.String("identityAddr", "http://localhost:10001/identity", "address of identity service")
 	identityKey  = flag.String("identityKey", "key-identity", "key for identity service")
 	presenceAddr = flag.String("presenceAddr", "http://localhost:10001/presence", "address of presence service")
 	presenceKey  = flag.String("presenceKey", "key-presence", "key for presence service")
+	cacheSize    = flag.Int("cacheSize", 0, "identity and presence lookup cache size (0 disables)")
+	cacheTTL     = flag.Duration("cacheTTL", time.Minute*5, "identity and presence lookup cache ttl")
 	noteAddr     = flag.String("noteAddr", "http://localhost:10001/notification", "address of notification service")
 	noteKey      = flag.String("noteKey", "key-note", "key for notification service")
+	noteBatchURL = flag.String("noteBatchURL", "", "batch path of notification service (empty sends batches one by one)")
+	noteWindow   = flag.Duration("noteWindow", friends.DefaultNoteBatchWindow, "how long notifications are held to be batched per recipient")
+	keySet       = flag.String("keySet", "", "path of the jwks file used to verify bnet keys locally")
+	keyMaxAge    = flag.Duration("keyMaxAge", platform.DefaultKeyMaxAge, "max age of a bnet key since creation")
+	keyKinds     = flag.String("keyKinds", "", "comma separated bnet key types allowed (default all)")
+	leaseTTL     = flag.Duration("leaseTTL", friends.DefaultLeaseTTL, "leader lease ttl for singleton daemon jobs")
+	idleAfter    = flag.Duration("idleAfter", friends.DefaultIdleThreshold, "inactivity before an online account is moved to idle")
+	requestTTL   = flag.Duration("requestTTL", friends.DefaultRequestTTL, "how long a friend request stays pending")
+	playedWindow = flag.Duration("playedWindow", friends.DefaultPlayedWindow, "how long accounts are kept in recently played lists")
 	apmKey       = flag.String("apmKey", "", "apm agent license key")
 	apmEnable    = flag.Bool("apmEnable", false, "enable apm agent")
 	cpuprofile   = flag.String("cpuprofile", "", "write cpu profile to file")
@@ -50,8 +65,24 @@ func main() {
 	// loaded by the above func (cmd.ParseFlagsOrEnv) if names match as env vars
 	conf := friends.Config{
 		Name: *name, Addr: *addr, Env: *env,
+		Private: *private, ServiceKey: *serviceKey, ServerKey: *serverKey,
 		Redis: redis.Config{Addr: strings.Split(*redisAddr, ","), Clustered: *redisCluster, TTL: -1, Retries: 3},
 		Relic: relic.Config{Name: *name, Key: *apmKey, Enabled: *apmEnable},
+
+		LeaseTTL:      *leaseTTL,
+		IdleThreshold: *idleAfter,
+		RequestTTL:    *requestTTL,
+		PlayedWindow:  *playedWindow,
+		NoteWindow:    *noteWindow,
+	}
+
+	// enable local bnet key verification if a key set is provided, otherwise the
+	// decoded key headers are trusted from the sidecar
+	if *keySet != "" {
+		conf.KeySet = platform.VerifierConfig{Path: *keySet, MaxAge: *keyMaxAge}
+		if *keyKinds != "" {
+			conf.KeySet.Kinds = strings.Split(*keyKinds, ",")
+		}
 	}
 
 	// aws.Describe gathers important container, and aws-ecr meta data if available
@@ -62,9 +93,9 @@ func main() {
 	// in the cloud these values are stored in AWS::SecretsManager by environment
 	// and loaded via AWS::ECS TaskDef on spinup.
 	conf.Provider = map[string]interface{}{
-		"identity": &provider.IdentityConfig{Config: convert(*identityAddr, *identityKey), LookupURL: "/v2/lookup/identity/"},
-		"presence": &provider.PresenceConfig{Config: convert(*presenceAddr, *presenceKey), PresenceURL: "/v1/presence", PresencePrivateURL: "/v1/presence-private"},
-		"note":     &provider.NoteConfig{Config: convert(*noteAddr, *noteKey), AnnounceURL: "/v1/announcement", NoteURL: "/v1/notification"},
+		"identity": &provider.IdentityConfig{Config: cached(convert(*identityAddr, *identityKey)), LookupURL: "/v2/lookup/identity/"},
+		"presence": &provider.PresenceConfig{Config: cached(convert(*presenceAddr, *presenceKey)), PresenceURL: "/v1/presence", PresencePrivateURL: "/v1/presence-private"},
+		"note":     &provider.NoteConfig{Config: convert(*noteAddr, *noteKey), AnnounceURL: "/v1/announcement", NoteURL: "/v1/notification", BatchURL: *noteBatchURL},
 		"storage":  &provider.StorageConfig{},
 	}
 
@@ -132,6 +163,12 @@ func convert(addr, key string) provider.Config {
 	return provider.Config{Addr: addr, Key: key}
 }
 
+// cached returns the provider config with the lookup cache settings applied
+func cached(c provider.Config) provider.Config {
+	c.CacheSize, c.CacheTTL = *cacheSize, *cacheTTL
+	return c
+}
+
 // check evals error, if non-nil, it throws the fatal error up the stack
 // to trapfatal, where it then calls log.Fatal
 func check(what string, err error) {
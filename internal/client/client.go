@@ -3,18 +3,100 @@ package client
 import (
 	"context"
 	"encoding/json"
+	"io/ioutil"
+	"math/rand"
+	"net"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BethesdaNet/friends-go/internal/platform"
 )
 
+const (
+	// DefaultRetries used if the options retries is zero. Set retries below zero
+	// to disable retries.
+	DefaultRetries = 2
+
+	// DefaultBackoff is the base delay between retries
+	DefaultBackoff = time.Millisecond * 50
+
+	// DefaultBackoffMax caps the delay between retries
+	DefaultBackoffMax = time.Second
+
+	// DefaultBudget is the ratio of retries allowed to requests sent
+	DefaultBudget = 0.2
+
+	// DefaultMaxIdleConns is the idle connection pool size per provider
+	DefaultMaxIdleConns = 64
+)
+
+// Options tune the http client used by a provider
+type Options struct {
+
+	// Timeout of a single attempt. Attempts are also bound by the request context
+	// so retries never outlive the callers deadline.
+	Timeout time.Duration
+
+	// Retries is the max number of retries after the first attempt
+	Retries int
+
+	// Backoff is the base delay which doubles after every retry up to BackoffMax.
+	// The actual delay is randomized between zero and the computed delay.
+	Backoff    time.Duration
+	BackoffMax time.Duration
+
+	// Budget limits retries to a ratio of the requests sent, preventing retries
+	// from amplifying load on a struggling dependency
+	Budget float64
+
+	// MaxIdleConns is the max idle (keep-alive) connections kept for the provider
+	MaxIdleConns int
+}
+
 // New creates a new bnet client used typically by providers
-func New(addr, key, env string, c Cache) *Client {
+func New(addr, key, env string, c Cache, o Options) *Client {
 	if c == nil {
 		c = NoCache{}
 	}
+	if o.Retries == 0 {
+		o.Retries = DefaultRetries
+	}
+	if o.Retries < 0 {
+		o.Retries = 0
+	}
+	if o.Backoff <= 0 {
+		o.Backoff = DefaultBackoff
+	}
+	if o.BackoffMax <= 0 {
+		o.BackoffMax = DefaultBackoffMax
+	}
+	if o.Budget <= 0 {
+		o.Budget = DefaultBudget
+	}
+	if o.MaxIdleConns <= 0 {
+		o.MaxIdleConns = DefaultMaxIdleConns
+	}
 	return &Client{
 		Addr:  addr,
 		Cache: c,
 		key:   key,
+		opt:   o,
+		http: &http.Client{
+			Timeout: o.Timeout,
+			Transport: &http.Transport{
+				Proxy:                 http.ProxyFromEnvironment,
+				DialContext:           (&net.Dialer{Timeout: time.Second * 3, KeepAlive: time.Second * 30}).DialContext,
+				MaxIdleConns:          o.MaxIdleConns,
+				MaxIdleConnsPerHost:   o.MaxIdleConns,
+				IdleConnTimeout:       time.Second * 90,
+				TLSHandshakeTimeout:   time.Second * 3,
+				ExpectContinueTimeout: time.Second,
+			},
+		},
+		budget: newBudget(o.Budget, float64(o.Retries)*10),
 	}
 }
 
@@ -24,22 +106,121 @@ type Client struct {
 	Addr  string
 	Cache Cache
 
-	key string
+	key    string
+	opt    Options
+	http   *http.Client
+	budget *budget
 }
 
-// Do sends the http request
+// Do sends the http request. Requests without a host are sent to the clients
+// addr, and idempotent requests are retried on network errors and 5xx replies.
 func (s *Client) Do(r *http.Request, data interface{}) error {
 	// defer newrelic.StartSegment(tx, fmt.Sprintf("provider_%s_client_%s", s.Name, "request")).End()
+	if r.URL.Host == "" {
+		u, err := url.Parse(strings.TrimSuffix(s.Addr, "/") + r.URL.RequestURI())
+		if err != nil {
+			return err
+		}
+		r.URL, r.Host = u, u.Host
+	}
+	if r.Header.Get(platform.HeaderKey) == "" && r.Header.Get(platform.HeaderKeyServer) == "" && s.key != "" {
+		r.Header.Set(platform.HeaderKeyServer, s.key)
+	}
 	return s.fetch(r.Context(), r, &data)
 }
 
 func (s *Client) fetch(ctx context.Context, cr *http.Request, data interface{}) error {
-	r, err := http.DefaultClient.Do(cr)
-	if err != nil {
-		return err
-	}
-	defer r.Body.Close()
-	rep := Reply{}
-	rep.Platform.Message = data
-	return json.NewDecoder(r.Body).Decode(&rep)
+	s.budget.deposit()
+	retry := idempotent(cr) && (cr.Body == nil || cr.GetBody != nil)
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && cr.Body != nil {
+			body, err := cr.GetBody()
+			if err != nil {
+				return err
+			}
+			cr.Body = body
+		}
+		r, err := s.http.Do(cr)
+		if err == nil && r.StatusCode < http.StatusInternalServerError {
+			defer r.Body.Close()
+			rep := Reply{}
+			rep.Platform.Message = data
+			return json.NewDecoder(r.Body).Decode(&rep)
+		}
+		if err == nil {
+			ioutil.ReadAll(r.Body)
+			r.Body.Close()
+			err = PlatformError{ctx: "bad status", Code: r.StatusCode}
+		}
+		if !retry || attempt >= s.opt.Retries || ctx.Err() != nil || !s.budget.withdraw() {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(s.backoff(attempt)):
+		}
+	}
+}
+
+// backoff returns a randomized exponential delay for the attempt (full jitter)
+func (s *Client) backoff(attempt int) time.Duration {
+	d := s.opt.Backoff << uint(attempt)
+	if d <= 0 || d > s.opt.BackoffMax {
+		d = s.opt.BackoffMax
+	}
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+type ctxKey int
+
+const retryKey ctxKey = iota
+
+// Retryable marks a request as safe to retry regardless of its method. Use it
+// for requests that do not mutate state but are sent with a POST.
+func Retryable(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), retryKey, true))
+}
+
+func idempotent(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	ok, _ := r.Context().Value(retryKey).(bool)
+	return ok
+}
+
+// budget is a token bucket limiting retries to a ratio of requests. Every request
+// deposits ratio tokens and every retry withdraws one token.
+type budget struct {
+	mu     sync.Mutex
+	ratio  float64
+	max    float64
+	tokens float64
+}
+
+func newBudget(ratio, max float64) *budget {
+	if max < 1 {
+		max = 1
+	}
+	return &budget{ratio: ratio, max: max, tokens: max}
+}
+
+func (b *budget) deposit() {
+	b.mu.Lock()
+	if b.tokens += b.ratio; b.tokens > b.max {
+		b.tokens = b.max
+	}
+	b.mu.Unlock()
+}
+
+func (b *budget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
 }
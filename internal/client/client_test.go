@@ -0,0 +1,77 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient(t *testing.T) {
+	var calls, fails int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if atomic.AddInt32(&fails, -1) >= 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"platform":{"code":2000,"message":{"name":"ok"}}}`))
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, "key", "local", nil, Options{Retries: 2, Backoff: time.Millisecond})
+	do := func(method string, fail int32) (string, error) {
+		atomic.StoreInt32(&calls, 0)
+		atomic.StoreInt32(&fails, fail)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		r, _ := http.NewRequest(method, "/v1/test", strings.NewReader("{}"))
+		out := struct {
+			Name string `json:"name"`
+		}{}
+		err := c.Do(r.WithContext(ctx), &out)
+		return out.Name, err
+	}
+
+	t.Run("Retry", func(t *testing.T) {
+		name, err := do(http.MethodGet, 2)
+		if err != nil || name != "ok" {
+			t.Fatalf("got %q (%v); want %q (%v)", name, err, "ok", nil)
+		}
+		if n := atomic.LoadInt32(&calls); n != 3 {
+			t.Errorf("got %d; want %d", n, 3)
+		}
+	})
+	/* ------------------------------------------------------------------------ */
+	t.Run("Exhausted", func(t *testing.T) {
+		if _, err := do(http.MethodGet, 5); err == nil {
+			t.Errorf("got %v; want %s", err, "error")
+		}
+		if n := atomic.LoadInt32(&calls); n != 3 {
+			t.Errorf("got %d; want %d", n, 3)
+		}
+	})
+	/* ------------------------------------------------------------------------ */
+	t.Run("Post", func(t *testing.T) {
+		if _, err := do(http.MethodPost, 1); err == nil {
+			t.Errorf("got %v; want %s", err, "error")
+		}
+		if n := atomic.LoadInt32(&calls); n != 1 {
+			t.Errorf("got %d; want %d", n, 1)
+		}
+	})
+	/* ------------------------------------------------------------------------ */
+	t.Run("Budget", func(t *testing.T) {
+		c.budget = newBudget(DefaultBudget, 1)
+		c.budget.tokens = 0
+		if _, err := do(http.MethodGet, 1); err == nil {
+			t.Errorf("got %v; want %s", err, "error")
+		}
+		if n := atomic.LoadInt32(&calls); n != 1 {
+			t.Errorf("got %d; want %d", n, 1)
+		}
+	})
+}
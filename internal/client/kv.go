@@ -32,3 +32,19 @@ func (k *kv) Value() interface{} {
 }
 func (k *kv) Expire() time.Time       { return k.expire }
 func (k kv) SetExpire(t time.Time) KV { k.expire = t; return &k }
+
+// NewItem returns a KVTimed holding any value, used to cache provider responses
+func NewItem(name string, val interface{}) KVTimed {
+	return &item{k: name, v: val}
+}
+
+type item struct {
+	k      string
+	v      interface{}
+	expire time.Time
+}
+
+func (i *item) Name() string            { return i.k }
+func (i *item) Value() interface{}      { return i.v }
+func (i *item) Expire() time.Time       { return i.expire }
+func (i item) SetExpire(t time.Time) KV { i.expire = t; return &i }
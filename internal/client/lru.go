@@ -0,0 +1,146 @@
+package client
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// NewLRU returns a cache holding at most size entries. Entries expire after ttl
+// unless they are KVTimed values with an expiry already set.
+func NewLRU(size int, ttl time.Duration) *LRU {
+	if size <= 0 {
+		size = DefaultCacheSize
+	}
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	return &LRU{
+		size:  size,
+		ttl:   ttl,
+		ll:    list.New(),
+		items: make(map[string]*list.Element, size),
+		now:   time.Now,
+	}
+}
+
+const (
+	// DefaultCacheSize used if the cache size is zero
+	DefaultCacheSize = 10000
+
+	// DefaultCacheTTL used if the cache ttl is zero
+	DefaultCacheTTL = time.Minute * 5
+)
+
+// LRU is a bounded in-memory cache with per-entry expiry. Once full the least
+// recently used entry is evicted to make room.
+type LRU struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	ll    *list.List
+	items map[string]*list.Element
+
+	hits, misses, evictions uint64
+
+	// now returns the current time and is replaced during testing
+	now func() time.Time
+}
+
+// CacheStats contains cache counters
+type CacheStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Size      int    `json:"size"`
+}
+
+type entry struct {
+	key string
+	kv  KVTimed
+}
+
+// Get returns the entry stored under path and key or nil if missing or expired
+func (c *LRU) Get(path, key string) KV {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[path+DeCacheKey+key]
+	if !ok {
+		atomic.AddUint64(&c.misses, 1)
+		return nil
+	}
+	e := el.Value.(*entry)
+	if !c.now().Before(e.kv.Expire()) {
+		c.remove(el)
+		atomic.AddUint64(&c.misses, 1)
+		return nil
+	}
+	c.ll.MoveToFront(el)
+	atomic.AddUint64(&c.hits, 1)
+	return e.kv
+}
+
+// Put stores val under path and val.Name(). It returns true if an entry was
+// evicted to make room.
+func (c *LRU) Put(path string, val KV) bool {
+	if val == nil {
+		return false
+	}
+	kv, ok := val.(KVTimed)
+	if !ok {
+		kv = NewItem(val.Name(), val.Value())
+	}
+	if kv.Expire().IsZero() {
+		kv = kv.SetExpire(c.now().Add(c.ttl)).(KVTimed)
+	}
+	key := path + DeCacheKey + kv.Name()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		el.Value.(*entry).kv = kv
+		c.ll.MoveToFront(el)
+		return false
+	}
+	c.items[key] = c.ll.PushFront(&entry{key: key, kv: kv})
+	if c.ll.Len() <= c.size {
+		return false
+	}
+	c.remove(c.ll.Back())
+	atomic.AddUint64(&c.evictions, 1)
+	return true
+}
+
+// Del removes and returns the entry stored under path and key
+func (c *LRU) Del(path, key string) (KV, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[path+DeCacheKey+key]
+	if !ok {
+		return nil, false
+	}
+	c.remove(el)
+	return el.Value.(*entry).kv, true
+}
+
+// Stats returns the cache counters
+func (c *LRU) Stats() CacheStats {
+	c.mu.Lock()
+	size := c.ll.Len()
+	c.mu.Unlock()
+	return CacheStats{
+		Hits:      atomic.LoadUint64(&c.hits),
+		Misses:    atomic.LoadUint64(&c.misses),
+		Evictions: atomic.LoadUint64(&c.evictions),
+		Size:      size,
+	}
+}
+
+func (c *LRU) remove(el *list.Element) {
+	c.ll.Remove(el)
+	delete(c.items, el.Value.(*entry).key)
+}
+
+// DeCacheKey delimits the path and key of a cache entry
+const DeCacheKey = "\x00"
@@ -0,0 +1,66 @@
+package client
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestLRU(t *testing.T) {
+	now := time.Now()
+	c := NewLRU(3, time.Minute)
+	c.now = func() time.Time { return now }
+
+	t.Run("Get", func(t *testing.T) {
+		c.Put("/a", NewItem("1", 1))
+		if kv := c.Get("/a", "1"); kv == nil || kv.Value() != 1 {
+			t.Fatalf("got %v; want %v", kv, 1)
+		}
+		if kv := c.Get("/b", "1"); kv != nil {
+			t.Errorf("got %v; want %v", kv, nil)
+		}
+	})
+	/* ------------------------------------------------------------------------ */
+	t.Run("Expire", func(t *testing.T) {
+		c.Put("/a", NewItem("2", 2).SetExpire(now.Add(time.Second)))
+		now = now.Add(time.Second * 2)
+		if kv := c.Get("/a", "2"); kv != nil {
+			t.Errorf("got %v; want %v", kv, nil)
+		}
+		if kv := c.Get("/a", "1"); kv == nil {
+			t.Errorf("got %v; want %v", kv, 1)
+		}
+		now = now.Add(time.Minute)
+		if kv := c.Get("/a", "1"); kv != nil {
+			t.Errorf("got %v; want %v", kv, nil)
+		}
+	})
+	/* ------------------------------------------------------------------------ */
+	t.Run("Evict", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			c.Put("/e", NewItem(fmt.Sprint(i), i))
+		}
+		c.Get("/e", "0") // 0 is now most recently used; 1 is evicted next
+		if evicted := c.Put("/e", NewItem("3", 3)); !evicted {
+			t.Errorf("got %v; want %v", evicted, true)
+		}
+		if kv := c.Get("/e", "1"); kv != nil {
+			t.Errorf("got %v; want %v", kv, nil)
+		}
+		for _, k := range []string{"0", "2", "3"} {
+			if kv := c.Get("/e", k); kv == nil {
+				t.Errorf("got %v; want %s", kv, k)
+			}
+		}
+		if _, ok := c.Del("/e", "3"); !ok {
+			t.Errorf("got %v; want %v", ok, true)
+		}
+	})
+	/* ------------------------------------------------------------------------ */
+	t.Run("Stats", func(t *testing.T) {
+		s := c.Stats()
+		if s.Hits != 6 || s.Misses != 4 || s.Evictions != 1 || s.Size != 2 {
+			t.Errorf("got %+v; want %+v", s, CacheStats{Hits: 6, Misses: 4, Evictions: 1, Size: 2})
+		}
+	})
+}
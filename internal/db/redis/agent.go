@@ -17,6 +17,11 @@ func Open(c Config) (*Agent, error) {
 	return agent, agent.dial()
 }
 
+// NewAgent creates a redis agent using an existing client
+func NewAgent(c Client, conf Config) *Agent {
+	return &Agent{client: c, config: conf}
+}
+
 // Agent controls and maintains db data source
 type Agent struct {
 	client Client
@@ -78,6 +83,32 @@ func (a *Agent) SetBytes(key string, data []byte) error {
 	return a.client.Set(key, data, a.config.TTL).Err()
 }
 
+// SetBytesExpire will put the kvp into redis expiring after the provided ttl. A
+// ttl of zero persists the record regardless of the agents configured ttl.
+func (a *Agent) SetBytesExpire(key string, data []byte, ttl time.Duration) error {
+	return a.client.Set(key, data, ttl).Err()
+}
+
+// SetNX puts the kvp into redis expiring after ttl only if key does not exist
+// and returns true if it was set
+func (a *Agent) SetNX(key string, value interface{}, ttl time.Duration) (bool, error) {
+	return a.client.SetNX(key, value, ttl).Result()
+}
+
+// Expire sets the ttl of the record stored at key
+func (a *Agent) Expire(key string, ttl time.Duration) error {
+	return a.client.Expire(key, ttl).Err()
+}
+
+// Exists returns true if every provided key is present in the db
+func (a *Agent) Exists(k ...string) (bool, error) {
+	n, err := a.client.Exists(k...).Result()
+	if err != nil {
+		return false, err
+	}
+	return n == int64(len(k)), nil
+}
+
 // Scan for all keys based on provided pattern
 func (a *Agent) Scan(value string, count int64) ([]string, error) {
 	if count == 0 {
@@ -98,9 +129,37 @@ func (a *Agent) Scan(value string, count int64) ([]string, error) {
 	return data, nil
 }
 
-// MGet fetches records from db
+// MGet fetches records from db returning them in key order. Keys of a clustered
+// db are fetched with one MGET per hash slot as a single MGET across slots
+// fails with CROSSSLOT; the MGETs are pipelined in one round trip.
 func (a *Agent) MGet(key ...string) ([]interface{}, error) {
-	return a.client.MGet(key...).Result()
+	if !a.config.Clustered {
+		return a.client.MGet(key...).Result()
+	}
+	slots := bySlot(key)
+	cmds := make([]*SliceCmd, len(slots))
+	if err := a.pipelined(func(c Client) {
+		for s, idx := range slots {
+			keys := make([]string, len(idx))
+			for i, n := range idx {
+				keys[i] = key[n]
+			}
+			cmds[s] = c.MGet(keys...)
+		}
+	}); err != nil {
+		return nil, err
+	}
+	out := make([]interface{}, len(key))
+	for s, idx := range slots {
+		rows, err := cmds[s].Result()
+		if err != nil {
+			return nil, err
+		}
+		for i, row := range rows {
+			out[idx[i]] = row
+		}
+	}
+	return out, nil
 }
 
 // Del removes db record by key
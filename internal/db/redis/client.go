@@ -47,7 +47,33 @@ type Client interface {
 	MGet(...string) *SliceCmd
 	Scan(uint64, string, int64) *ScanCmd
 	Set(string, interface{}, time.Duration) *StatusCmd
+	SetNX(string, interface{}, time.Duration) *BoolCmd
 	Del(...string) *IntCmd
+	Exists(...string) *IntCmd
+	Incr(string) *IntCmd
+	Eval(string, []string, ...interface{}) *Cmd
+	Expire(string, time.Duration) *BoolCmd
+	SAdd(string, ...interface{}) *IntCmd
+	SRem(string, ...interface{}) *IntCmd
+	SCard(string) *IntCmd
+	SIsMember(string, interface{}) *BoolCmd
+	SMembers(string) *StringSliceCmd
+	SInter(...string) *StringSliceCmd
+	SPopN(string, int64) *StringSliceCmd
+	LPush(string, ...interface{}) *IntCmd
+	LLen(string) *IntCmd
+	LRange(string, int64, int64) *StringSliceCmd
+	LRem(string, int64, interface{}) *IntCmd
+	RPop(string) *StringCmd
+	RPopLPush(string, string) *StringCmd
+	BRPopLPush(string, string, time.Duration) *StringCmd
+	ZAdd(string, ...goredis.Z) *IntCmd
+	ZRangeByScore(string, goredis.ZRangeBy) *StringSliceCmd
+	ZRem(string, ...interface{}) *IntCmd
+	HSet(string, string, interface{}) *BoolCmd
+	HMSet(string, map[string]interface{}) *StatusCmd
+	HDel(string, ...string) *IntCmd
+	HGetAll(string) *StringStringMapCmd
 	Ping() *StatusCmd
 	Close() error
 }
@@ -55,11 +81,15 @@ type (
 	Options        = goredis.Options
 	ClusterOptions = goredis.ClusterOptions
 
-	IntCmd    = goredis.IntCmd
-	SliceCmd  = goredis.SliceCmd
-	StatusCmd = goredis.StatusCmd
-	StringCmd = goredis.StringCmd
-	ScanCmd   = goredis.ScanCmd
+	BoolCmd            = goredis.BoolCmd
+	Cmd                = goredis.Cmd
+	IntCmd             = goredis.IntCmd
+	SliceCmd           = goredis.SliceCmd
+	StatusCmd          = goredis.StatusCmd
+	StringCmd          = goredis.StringCmd
+	StringSliceCmd     = goredis.StringSliceCmd
+	StringStringMapCmd = goredis.StringStringMapCmd
+	ScanCmd            = goredis.ScanCmd
 )
 
 func check(addr []string) []string {
@@ -0,0 +1,47 @@
+package redis
+
+// HSet sets field in the hash stored at key to value
+func (a *Agent) HSet(key, field string, value interface{}) error {
+	return a.client.HSet(key, field, value).Err()
+}
+
+// HMSet sets every field of fields in the hash stored at key in one round trip
+func (a *Agent) HMSet(key string, fields map[string]interface{}) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	return a.client.HMSet(key, fields).Err()
+}
+
+// HDel removes fields from the hash stored at key
+func (a *Agent) HDel(key string, field ...string) error {
+	return a.client.HDel(key, field...).Err()
+}
+
+// HGetAll returns all fields and values of the hash stored at key
+func (a *Agent) HGetAll(key string) (map[string]string, error) {
+	return a.client.HGetAll(key).Result()
+}
+
+// hdelIfScript removes each field only while it still holds the given value
+const hdelIfScript = `local n = 0
+for i = 1, #ARGV, 2 do
+	if redis.call("hget", KEYS[1], ARGV[i]) == ARGV[i + 1] then
+		n = n + redis.call("hdel", KEYS[1], ARGV[i])
+	end
+end
+return n`
+
+// HDelIf removes the fields of the hash stored at key which still hold the given
+// values, leaving fields changed since they were read. It returns how many
+// fields were removed.
+func (a *Agent) HDelIf(key string, fields map[string]string) (int64, error) {
+	if len(fields) == 0 {
+		return 0, nil
+	}
+	args := make([]interface{}, 0, len(fields)*2)
+	for k, v := range fields {
+		args = append(args, k, v)
+	}
+	return a.client.Eval(hdelIfScript, []string{key}, args...).Int64()
+}
@@ -0,0 +1,153 @@
+package redis
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// renewScript extends the lease only while it is still held by the caller
+const renewScript = `if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("pexpire", KEYS[1], ARGV[2])
+end
+return 0`
+
+// releaseScript removes the lease only while it is still held by the caller
+const releaseScript = `if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("del", KEYS[1])
+end
+return 0`
+
+// LeaseMargin is the part of the lease ttl a holder stops trusting its lease
+// before it expires, allowing for clock drift and the latency of renewal
+const LeaseMargin = 5
+
+// Lease is a redis lock held by a single instance at a time. Each acquisition
+// is given a token from an increasing counter which is stored as part of the
+// lock value; an instance whose lease expired and was taken over can no longer
+// renew or release it. The token only tells holders apart, writes made by a
+// holder are not checked against it.
+type Lease struct {
+	agent   *Agent
+	key     string
+	counter string
+	owner   string
+	ttl     time.Duration
+
+	mu      sync.Mutex
+	value   string
+	token   int64
+	expires time.Time
+
+	// now returns the current time and is replaced during testing
+	now func() time.Time
+}
+
+// NewLease creates a lease stored at key. The key is wrapped in a hash tag so
+// the lock and token counter share a cluster slot.
+func NewLease(a *Agent, key string, ttl time.Duration) *Lease {
+	return &Lease{
+		agent:   a,
+		key:     "{" + key + "}",
+		counter: "{" + key + "}.token",
+		owner:   NewOwner(),
+		ttl:     ttl,
+		now:     time.Now,
+	}
+}
+
+// NewOwner returns an id unique to this process used to tell instances apart in
+// locks and queues
+func NewOwner() string {
+	host, _ := os.Hostname()
+	b := make([]byte, 8)
+	rand.Read(b)
+	return fmt.Sprintf("%s-%d-%x", host, os.Getpid(), b)
+}
+
+// Acquire renews the lease if held or attempts to take it otherwise, returning
+// true if the lease is held once it returns
+func (l *Lease) Acquire() (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	start := l.now()
+	if l.value != "" {
+		n, err := l.agent.client.Eval(renewScript, []string{l.key}, l.value, l.ttl.Milliseconds()).Int64()
+		if err != nil {
+			return false, err
+		}
+		if n == 1 {
+			l.expires = start.Add(l.ttl - l.ttl/LeaseMargin)
+			return true, nil
+		}
+		l.value, l.token = "", 0
+	}
+
+	// a token is drawn for every attempt so tokens only ever increase
+	token, err := l.agent.client.Incr(l.counter).Result()
+	if err != nil {
+		return false, err
+	}
+	value := l.owner + ":" + strconv.FormatInt(token, 10)
+	ok, err := l.agent.client.SetNX(l.key, value, l.ttl).Result()
+	if err != nil || !ok {
+		return false, err
+	}
+	l.value, l.token = value, token
+	l.expires = start.Add(l.ttl - l.ttl/LeaseMargin)
+	return true, nil
+}
+
+// Held returns true if the lease was held as of the last acquisition and has
+// not yet expired
+func (l *Lease) Held() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.value != "" && l.now().Before(l.expires)
+}
+
+// Token returns the token of the current lease or zero if not held
+func (l *Lease) Token() int64 {
+	if !l.Held() {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.token
+}
+
+// Current returns the token of whoever holds the lease in the db, or zero if
+// nobody does
+func (l *Lease) Current() (int64, error) {
+	raw, err := l.agent.GetBytes(l.key)
+	switch err {
+	case nil:
+	case ErrBadKey:
+		return 0, nil
+	default:
+		return 0, err
+	}
+	v := string(raw)
+	token, err := strconv.ParseInt(v[strings.LastIndex(v, ":")+1:], 10, 64)
+	if err != nil {
+		return 0, ErrBadKey
+	}
+	return token, nil
+}
+
+// Release gives up the lease if still held so another instance can take it
+// without waiting for it to expire
+func (l *Lease) Release() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.value == "" {
+		return nil
+	}
+	value := l.value
+	l.value, l.token = "", 0
+	return l.agent.client.Eval(releaseScript, []string{l.key}, value).Err()
+}
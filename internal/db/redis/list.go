@@ -0,0 +1,52 @@
+package redis
+
+import (
+	"time"
+
+	goredis "github.com/go-redis/redis"
+)
+
+// LPush prepends values to the list stored at key returning the new length
+func (a *Agent) LPush(key string, value ...string) (int64, error) {
+	return a.client.LPush(key, members(value)...).Result()
+}
+
+// LLen returns the length of the list stored at key
+func (a *Agent) LLen(key string) (int64, error) {
+	return a.client.LLen(key).Result()
+}
+
+// LRange returns the elements start through stop of the list stored at key
+func (a *Agent) LRange(key string, start, stop int64) ([]string, error) {
+	return a.client.LRange(key, start, stop).Result()
+}
+
+// LRem removes up to count occurrences of value from the list stored at key
+func (a *Agent) LRem(key string, count int64, value string) error {
+	return a.client.LRem(key, count, value).Err()
+}
+
+// RPop removes and returns the last element of the list stored at key. An empty
+// list returns ErrBadKey.
+func (a *Agent) RPop(key string) (string, error) {
+	return nilKey(a.client.RPop(key).Result())
+}
+
+// RPopLPush atomically moves the last element of src to the head of dst and
+// returns it. An empty src returns ErrBadKey.
+func (a *Agent) RPopLPush(src, dst string) (string, error) {
+	return nilKey(a.client.RPopLPush(src, dst).Result())
+}
+
+// BRPopLPush is RPopLPush blocking up to timeout for an element to arrive. If
+// none arrives ErrBadKey is returned.
+func (a *Agent) BRPopLPush(src, dst string, timeout time.Duration) (string, error) {
+	return nilKey(a.client.BRPopLPush(src, dst, timeout).Result())
+}
+
+func nilKey(v string, err error) (string, error) {
+	if err == goredis.Nil {
+		return "", ErrBadKey
+	}
+	return v, err
+}
@@ -0,0 +1,71 @@
+package redis
+
+import goredis "github.com/go-redis/redis"
+
+// pipeliner is implemented by clients able to send queued commands in one round
+// trip. A clustered client splits the pipeline per node.
+type pipeliner interface {
+	Pipeline() goredis.Pipeliner
+}
+
+// pipelined queues the commands issued by fn and sends them in one round trip.
+// Clients which do not pipeline run the commands as fn issues them. Callers read
+// each command result once it returns.
+func (a *Agent) pipelined(fn func(Client)) error {
+	p, ok := a.client.(pipeliner)
+	if !ok {
+		fn(a.client)
+		return nil
+	}
+	pipe := p.Pipeline()
+	defer pipe.Close()
+	fn(pipe)
+	if _, err := pipe.Exec(); err != nil && err != goredis.Nil {
+		return err
+	}
+	return nil
+}
+
+// SMembersAll returns the members of each set stored at keys in key order. The
+// sets are read in one round trip.
+func (a *Agent) SMembersAll(keys ...string) ([][]string, error) {
+	cmds := make([]*StringSliceCmd, len(keys))
+	if err := a.pipelined(func(c Client) {
+		for i, k := range keys {
+			cmds[i] = c.SMembers(k)
+		}
+	}); err != nil {
+		return nil, err
+	}
+	out := make([][]string, len(keys))
+	for i, cmd := range cmds {
+		set, err := cmd.Result()
+		if err != nil {
+			return nil, err
+		}
+		out[i] = set
+	}
+	return out, nil
+}
+
+// SIsMemberAll returns whether member is part of each set stored at keys in key
+// order. The sets are checked in one round trip.
+func (a *Agent) SIsMemberAll(member string, keys ...string) ([]bool, error) {
+	cmds := make([]*BoolCmd, len(keys))
+	if err := a.pipelined(func(c Client) {
+		for i, k := range keys {
+			cmds[i] = c.SIsMember(k, member)
+		}
+	}); err != nil {
+		return nil, err
+	}
+	out := make([]bool, len(keys))
+	for i, cmd := range cmds {
+		ok, err := cmd.Result()
+		if err != nil {
+			return nil, err
+		}
+		out[i] = ok
+	}
+	return out, nil
+}
@@ -0,0 +1,109 @@
+package redis
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"sync"
+
+	goredis "github.com/go-redis/redis"
+)
+
+// ErrBadClient returned when the agents client does not support node commands
+var ErrBadClient = errors.New("dba: unsupported client")
+
+// KeyspaceConfig is the redis config parameter enabling keyspace notifications
+const KeyspaceConfig = "notify-keyspace-events"
+
+// Message is a pubsub message received by Listen
+type Message = goredis.Message
+
+// nodes calls fn with the client of every master node. A default client is its
+// own single node.
+func (a *Agent) nodes(fn func(*goredis.Client) error) error {
+	switch c := a.client.(type) {
+	case *goredis.ClusterClient:
+		return c.ForEachMaster(fn)
+	case *goredis.Client:
+		return fn(c)
+	default:
+		return ErrBadClient
+	}
+}
+
+// EnableKeyspace adds flags to the keyspace notification config of every node,
+// keeping flags already enabled. Managed redis deployments may not allow CONFIG
+// in which case notifications must be enabled through the deployment instead.
+func (a *Agent) EnableKeyspace(flags string) error {
+	return a.nodes(func(c *goredis.Client) error {
+		cur := ""
+		if v, err := c.ConfigGet(KeyspaceConfig).Result(); err == nil && len(v) == 2 {
+			cur, _ = v[1].(string)
+		}
+		next := cur
+		for _, f := range flags {
+			if !strings.ContainsRune(next, f) {
+				next += string(f)
+			}
+		}
+		if next == cur {
+			return nil
+		}
+		return c.ConfigSet(KeyspaceConfig, next).Err()
+	})
+}
+
+// Listen subscribes to patterns on every node and calls fn with each message
+// until done is closed. Keyspace notifications are only published by the node
+// owning the key, so a single cluster subscription would miss most of them.
+func (a *Agent) Listen(done <-chan struct{}, fn func(*Message), pattern ...string) error {
+	// nodes are visited concurrently for clustered clients
+	mu, subs := sync.Mutex{}, []*goredis.PubSub{}
+	err := a.nodes(func(c *goredis.Client) error {
+		ps := c.PSubscribe(pattern...)
+		if _, err := ps.Receive(); err != nil {
+			ps.Close()
+			return err
+		}
+		mu.Lock()
+		subs = append(subs, ps)
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		for _, ps := range subs {
+			ps.Close()
+		}
+		return err
+	}
+
+	wg := sync.WaitGroup{}
+	for _, ps := range subs {
+		wg.Add(1)
+		go func(ps *goredis.PubSub) {
+			defer wg.Done()
+			ch := ps.Channel()
+			for {
+				select {
+				case <-done:
+					return
+				case msg, ok := <-ch:
+					if !ok {
+						return
+					}
+					fn(msg)
+				}
+			}
+		}(ps)
+	}
+	go func() {
+		<-done
+		for _, ps := range subs {
+			if err := ps.Close(); err != nil {
+				log.Printf("dba: listen: close: %v", err)
+			}
+		}
+		wg.Wait()
+	}()
+	return nil
+}
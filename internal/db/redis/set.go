@@ -0,0 +1,97 @@
+package redis
+
+// SAdd adds members to the set stored at key
+func (a *Agent) SAdd(key string, member ...string) error {
+	return a.client.SAdd(key, members(member)...).Err()
+}
+
+// saddUnlessScript adds a member to the first set unless any of the sets already
+// holds it, returning the key of the set holding it
+const saddUnlessScript = `for i = 1, #KEYS do
+	if redis.call("sismember", KEYS[i], ARGV[1]) == 1 then
+		return KEYS[i]
+	end
+end
+redis.call("sadd", KEYS[1], ARGV[1])
+return ""`
+
+// SAddUnless adds member to the set stored at key unless it is already part of
+// that set or of any of the sets stored at others, as one atomic step. Keys must
+// share a cluster slot. It returns the key of the set already holding member,
+// or an empty string once member was added.
+func (a *Agent) SAddUnless(key, member string, others ...string) (string, error) {
+	return a.client.Eval(saddUnlessScript, append([]string{key}, others...), member).String()
+}
+
+// SRem removes members from the set stored at key
+func (a *Agent) SRem(key string, member ...string) error {
+	return a.client.SRem(key, members(member)...).Err()
+}
+
+// SRemCount removes members from the set stored at key returning how many were
+// removed. Callers racing to remove the same member can use the count to decide
+// which of them removed it.
+func (a *Agent) SRemCount(key string, member ...string) (int64, error) {
+	return a.client.SRem(key, members(member)...).Result()
+}
+
+// SCard returns the number of members in the set stored at key
+func (a *Agent) SCard(key string) (int64, error) {
+	return a.client.SCard(key).Result()
+}
+
+// SIsMember returns true if member is part of the set stored at key
+func (a *Agent) SIsMember(key, member string) (bool, error) {
+	return a.client.SIsMember(key, member).Result()
+}
+
+// SMembers returns all members of the set stored at key
+func (a *Agent) SMembers(key string) ([]string, error) {
+	return a.client.SMembers(key).Result()
+}
+
+// SPopN removes and returns up to count random members of the set stored at key
+func (a *Agent) SPopN(key string, count int64) ([]string, error) {
+	return a.client.SPopN(key, count).Result()
+}
+
+// SInter returns the members common to every set stored at keys. Keys of a
+// clustered db rarely share a slot, so the intersection is computed locally
+// from each set rather than with SINTER which would fail with CROSSSLOT.
+func (a *Agent) SInter(keys ...string) ([]string, error) {
+	if !a.config.Clustered {
+		return a.client.SInter(keys...).Result()
+	}
+	var out []string
+	for i, key := range keys {
+		set, err := a.client.SMembers(key).Result()
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			out = set
+			continue
+		}
+		has := make(map[string]bool, len(set))
+		for _, v := range set {
+			has[v] = true
+		}
+		n := 0
+		for _, v := range out {
+			if has[v] {
+				out[n] = v
+				n++
+			}
+		}
+		out = out[:n]
+	}
+	return out, nil
+}
+
+func members(in []string) []interface{} {
+	out := make([]interface{}, len(in))
+	for i, v := range in {
+		out[i] = v
+	}
+	return out
+}
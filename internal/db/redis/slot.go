@@ -0,0 +1,50 @@
+package redis
+
+import "strings"
+
+// Slots is the number of hash slots of a redis cluster
+const Slots = 16384
+
+// Slot returns the cluster hash slot of key. Only the part of the key inside the
+// first non-empty {hash tag} is hashed so keys sharing a tag share a slot.
+func Slot(key string) int {
+	if s := strings.IndexByte(key, '{'); s > -1 {
+		if e := strings.IndexByte(key[s+1:], '}'); e > 0 {
+			key = key[s+1 : s+e+1]
+		}
+	}
+	return int(crc16(key) % Slots)
+}
+
+// crc16 is the CCITT (XMODEM) checksum redis uses to map keys to slots
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// bySlot groups the indexes of keys by hash slot keeping their order within
+// each slot; slots are returned in order of their first key
+func bySlot(keys []string) [][]int {
+	out, at := [][]int{}, map[int]int{}
+	for i, k := range keys {
+		slot := Slot(k)
+		n, ok := at[slot]
+		if !ok {
+			n = len(out)
+			at[slot] = n
+			out = append(out, nil)
+		}
+		out[n] = append(out[n], i)
+	}
+	return out
+}
@@ -0,0 +1,25 @@
+package redis
+
+import "testing"
+
+func TestSlot(t *testing.T) {
+	for _, tc := range []struct {
+		key  string
+		want int
+	}{
+		{"123456789", 12739},
+		{"foo", 12182},
+		{"{foo}.friends", 12182},
+		{"a{foo}b{bar}", 12182},
+	} {
+		if got := Slot(tc.key); got != tc.want {
+			t.Errorf("%s: got %v; want %v", tc.key, got, tc.want)
+		}
+	}
+	t.Run("Group", func(t *testing.T) {
+		got := bySlot([]string{"{a}.x", "{b}.x", "{a}.y"})
+		if len(got) != 2 || len(got[0]) != 2 || got[0][1] != 2 || got[1][0] != 1 {
+			t.Errorf("got %v; want %v", got, "[[0 2] [1]]")
+		}
+	})
+}
@@ -0,0 +1,28 @@
+package redis
+
+import (
+	"strconv"
+
+	goredis "github.com/go-redis/redis"
+)
+
+// ZAdd adds member to the sorted set stored at key with score
+func (a *Agent) ZAdd(key string, score float64, member string) error {
+	return a.client.ZAdd(key, goredis.Z{Score: score, Member: member}).Err()
+}
+
+// ZRangeByScore returns up to count members of the sorted set stored at key with
+// a score between min and max (inclusive)
+func (a *Agent) ZRangeByScore(key string, min, max float64, count int64) ([]string, error) {
+	return a.client.ZRangeByScore(key, goredis.ZRangeBy{
+		Min:   strconv.FormatFloat(min, 'f', -1, 64),
+		Max:   strconv.FormatFloat(max, 'f', -1, 64),
+		Count: count,
+	}).Result()
+}
+
+// ZRem removes members from the sorted set stored at key returning how many were
+// removed
+func (a *Agent) ZRem(key string, member ...string) (int64, error) {
+	return a.client.ZRem(key, members(member)...).Result()
+}
@@ -0,0 +1,146 @@
+package friends
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// Notifications to the same recipient are held in a hash for the note window
+// and queued together as a single batch. Each notification is stored under a
+// field naming what it supersedes, so a later presence update about the same
+// account replaces the earlier one instead of being sent after it.
+const (
+	KyNoteBatchDue  = "{friends.notes}.batch.due"
+	PaNoteBatch     = "{%s}.notes.batch"
+	PaNoteBatchWait = "{%s}.notes.batch.wait"
+)
+
+const (
+	// DefaultNoteBatchWindow is how long notifications are held to be batched if
+	// the configured window is zero
+	DefaultNoteBatchWindow = time.Second * 2
+
+	// MaxNoteBatch limits how many notifications are sent in one batch. Larger
+	// batches are split.
+	MaxNoteBatch = 50
+
+	// NoteBatch is the title of a queued batch of notifications
+	NoteBatch = "Notification Batch"
+)
+
+// batch holds q to be queued with the other notifications sent to its recipient
+// within the note window. The first notification of a window schedules it.
+func (m *Manager) batch(q *Queued, field string) error {
+	b, _ := json.Marshal(q)
+	if err := m.dba.HSet(fmt.Sprintf(PaNoteBatch, q.BUID), field, string(b)); err != nil {
+		return err
+	}
+	return m.scheduleBatch(q.BUID)
+}
+
+// scheduleBatch schedules the batch of buid to be queued once the note window
+// has passed unless it is already scheduled
+func (m *Manager) scheduleBatch(buid string) error {
+	ok, err := m.dba.SetNX(fmt.Sprintf(PaNoteBatchWait, buid), 1, m.batchWindow()+NotePoll*5)
+	if err != nil || !ok {
+		return err
+	}
+	due := time.Now().Add(m.batchWindow())
+	return m.dba.ZAdd(KyNoteBatchDue, float64(due.UnixNano()), buid)
+}
+
+// flushBatches queues the batches whose note window has passed. Removal from
+// the due set decides which instance queues a batch when several find it due.
+func (m *Manager) flushBatches() {
+	due, err := m.dba.ZRangeByScore(KyNoteBatchDue, 0, float64(time.Now().UnixNano()), 100)
+	if err != nil {
+		log.Printf("manager: notes: batches: %v", err)
+		return
+	}
+	for _, buid := range due {
+		if n, err := m.dba.ZRem(KyNoteBatchDue, buid); err != nil || n == 0 {
+			continue
+		}
+		if err := m.flushBatch(buid); err != nil {
+			log.Printf("manager: notes: batch %s: %v", buid, err)
+		}
+	}
+}
+
+// flushBatch queues the held notifications of buid oldest first. Notifications
+// are removed from the hash only once queued and only if not replaced since
+// they were read; anything left over is scheduled again.
+func (m *Manager) flushBatch(buid string) error {
+	key := fmt.Sprintf(PaNoteBatch, buid)
+	if err := m.dba.Del(fmt.Sprintf(PaNoteBatchWait, buid)); err != nil {
+		return err
+	}
+	rows, err := m.dba.HGetAll(key)
+	if err != nil || len(rows) == 0 {
+		return err
+	}
+	items := make([]*Queued, 0, len(rows))
+	for _, raw := range rows {
+		q := &Queued{}
+		if err := json.Unmarshal([]byte(raw), q); err != nil {
+			log.Printf("manager: notes: batch %s: discarded undecodable notification: %v", buid, err)
+			continue
+		}
+		items = append(items, q)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Time.Before(items[j].Time) })
+
+	for len(items) > 0 {
+		n := len(items)
+		if n > MaxNoteBatch {
+			n = MaxNoteBatch
+		}
+		if err := m.enqueue(batchOf(buid, items[:n])); err != nil {
+			return err
+		}
+		items = items[n:]
+	}
+	if _, err := m.dba.HDelIf(key, rows); err != nil {
+		return err
+	}
+	if ok, err := m.dba.Exists(key); err != nil || !ok {
+		return err
+	}
+	return m.scheduleBatch(buid)
+}
+
+// batchOf returns the queued notification delivering items to buid; a single
+// notification is queued as is
+func batchOf(buid string, items []*Queued) *Queued {
+	if len(items) == 1 {
+		return items[0]
+	}
+	return &Queued{ID: noteID(), Title: NoteBatch, BUID: buid, Batch: items, Time: time.Now().UTC()}
+}
+
+// supersedes returns the batch field a notification is held under. Presence
+// notifications about the same account, or the same status of the recipient,
+// share a field so only the latest is sent; other notifications are never
+// merged.
+func supersedes(q *Queued, data interface{}) string {
+	switch d := data.(type) {
+	case *PresenceChange:
+		return "presence." + d.BUID
+	case *Status:
+		if q.Title == NotePresenceUpdate {
+			return "status." + d.Product + "." + d.Platform
+		}
+	}
+	return q.ID
+}
+
+// batchWindow returns how long notifications are held to be batched
+func (m *Manager) batchWindow() time.Duration {
+	if m.noteWindow <= 0 {
+		return DefaultNoteBatchWindow
+	}
+	return m.noteWindow
+}
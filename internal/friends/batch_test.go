@@ -0,0 +1,59 @@
+package friends
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestBatch(t *testing.T) {
+	m, c := newTestManager()
+	m.SendNotification(NoteCameOnline, "a", &PresenceChange{BUID: "b", Status: "online"}, false)
+	m.SendNotification(NoteFriendRequestExpired, "a", &Request{From: "a", To: "c"}, false)
+	m.SendNotification(NoteWentIdle, "a", &PresenceChange{BUID: "b", Status: "idle"}, false)
+	m.SendNotification(NoteCameOnline, "a", &PresenceChange{BUID: "c", Status: "online"}, false)
+	m.SendNotification(NoteCameOnline, "d", &PresenceChange{BUID: "b", Status: "online"}, false)
+
+	if n, _ := m.dba.LLen(KyNoteQueue); n != 0 {
+		t.Errorf("got %v; want %v", n, 0)
+	}
+	if n := len(c.zsets[KyNoteBatchDue]); n != 2 {
+		t.Errorf("got %v; want %v", n, 2)
+	}
+
+	// batches are not queued before the note window has passed
+	m.flushBatches()
+	if n, _ := m.dba.LLen(KyNoteQueue); n != 0 {
+		t.Errorf("got %v; want %v", n, 0)
+	}
+	flushNotes(m, c)
+	if n, _ := m.dba.LLen(KyNoteQueue); n != 2 {
+		t.Fatalf("got %v; want %v", n, 2)
+	}
+	if n := len(c.hash[fmt.Sprintf(PaNoteBatch, "a")]); n != 0 {
+		t.Errorf("got %v; want %v", n, 0)
+	}
+
+	got := map[string][]string{}
+	for {
+		raw, err := m.dba.RPop(KyNoteQueue)
+		if err != nil {
+			break
+		}
+		q := &Queued{}
+		json.Unmarshal([]byte(raw), q)
+		if len(q.Batch) == 0 {
+			q.Batch = []*Queued{q}
+		}
+		for _, n := range q.Batch {
+			got[q.BUID] = append(got[q.BUID], n.Title)
+		}
+	}
+	want := []string{NoteFriendRequestExpired, NoteWentIdle, NoteCameOnline}
+	if fmt.Sprint(got["a"]) != fmt.Sprint(want) {
+		t.Errorf("got %v; want %v", got["a"], want)
+	}
+	if len(got["d"]) != 1 {
+		t.Errorf("got %v; want %v", got["d"], []string{NoteCameOnline})
+	}
+}
@@ -0,0 +1,129 @@
+package friends
+
+import (
+	"fmt"
+	"log"
+	"sort"
+)
+
+// MaxBlocks limits how many accounts a single account may block
+const MaxBlocks = 1000
+
+// Block is a single entry in an accounts block list
+type Block struct {
+	BUID string `json:"buid"`
+	Name string `json:"username"`
+}
+
+// Block adds target to the block list of buid. Any friendship or pending request
+// between the two accounts is removed without notifying either side.
+func (m *Manager) Block(buid, target string) error {
+	switch {
+	case buid == "" || target == "":
+		return ErrBadBUID
+	case buid == target:
+		return ErrSelfBlock
+	}
+	if n, err := m.dba.SCard(fmt.Sprintf(PaBlocks, buid)); err != nil {
+		return err
+	} else if n >= MaxBlocks {
+		return ErrMaxBlocks
+	}
+	if err := m.dba.SAdd(fmt.Sprintf(PaBlocks, buid), target); err != nil {
+		return err
+	}
+	if err := m.dba.SAdd(fmt.Sprintf(PaBlockedBy, target), buid); err != nil {
+		return err
+	}
+	return m.sever(buid, target)
+}
+
+// Unblock removes target from the block list of buid
+func (m *Manager) Unblock(buid, target string) error {
+	if buid == "" || target == "" {
+		return ErrBadBUID
+	}
+	ok, err := m.dba.SIsMember(fmt.Sprintf(PaBlocks, buid), target)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotBlocked
+	}
+	if err := m.dba.SRem(fmt.Sprintf(PaBlocks, buid), target); err != nil {
+		return err
+	}
+	return m.dba.SRem(fmt.Sprintf(PaBlockedBy, target), buid)
+}
+
+// GetBlocks returns the accounts blocked by buid sorted by username
+func (m *Manager) GetBlocks(buid string) ([]*Block, error) {
+	if buid == "" {
+		return nil, ErrBadBUID
+	}
+	ids, err := m.dba.SMembers(fmt.Sprintf(PaBlocks, buid))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*Block, len(ids))
+	for i, id := range ids {
+		out[i] = &Block{BUID: id}
+	}
+	if len(ids) > 0 && m.identity != nil {
+		accounts, err := m.GetAccounts(ids...)
+		if err != nil {
+			log.Printf("manager: blocks: %v", err)
+		}
+		for _, b := range out {
+			if a, ok := accounts[b.BUID]; ok {
+				b.Name = a.Name
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Name != out[j].Name {
+			return out[i].Name < out[j].Name
+		}
+		return out[i].BUID < out[j].BUID
+	})
+	return out, nil
+}
+
+// IsBlocked returns true if either account has blocked the other. Every friends
+// operation between two accounts must check this before proceeding.
+func (m *Manager) IsBlocked(a, b string) (bool, error) {
+	ok, err := m.dba.SIsMember(fmt.Sprintf(PaBlocks, a), b)
+	if err != nil || ok {
+		return ok, err
+	}
+	return m.dba.SIsMember(fmt.Sprintf(PaBlocks, b), a)
+}
+
+// blocked returns every account buid may not interact with; both the accounts
+// it has blocked and the accounts that have blocked it.
+func (m *Manager) blocked(buid string) (map[string]bool, error) {
+	out := map[string]bool{}
+	for _, pattern := range []string{PaBlocks, PaBlockedBy} {
+		ids, err := m.dba.SMembers(fmt.Sprintf(pattern, buid))
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			out[id] = true
+		}
+	}
+	return out, nil
+}
+
+// sever quietly removes the friendship and pending requests between a and b
+func (m *Manager) sever(a, b string) error {
+	if err := m.RemoveFriend(a, b); err != nil && err != ErrNotFriends {
+		return err
+	}
+	for _, edge := range [][2]string{{a, b}, {b, a}} {
+		if err := m.delRequest(edge[0], edge[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
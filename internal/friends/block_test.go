@@ -0,0 +1,68 @@
+package friends
+
+import (
+	"testing"
+)
+
+func TestBlocks(t *testing.T) {
+	m, _ := newTestManager()
+	if err := m.addFriend("a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	for _, req := range [][2]string{{"a", "c"}, {"d", "a"}} {
+		if _, err := m.SendRequest(req[0], req[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("Block", func(t *testing.T) {
+		if err := m.Block("a", "a"); err != ErrSelfBlock {
+			t.Errorf("got %v; want %v", err, ErrSelfBlock)
+		}
+		for _, id := range []string{"b", "c", "d"} {
+			if err := m.Block("a", id); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if ok, _ := m.IsFriend("b", "a"); ok {
+			t.Errorf("got %v; want %v", ok, false)
+		}
+		for _, id := range []string{"a", "c", "d"} {
+			reqs, err := m.GetRequests(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(reqs.Incoming)+len(reqs.Outgoing) != 0 {
+				t.Errorf("%s: got %+v; want none", id, reqs)
+			}
+		}
+	})
+	/* ------------------------------------------------------------------------ */
+	t.Run("Request", func(t *testing.T) {
+		for _, req := range [][2]string{{"a", "b"}, {"b", "a"}} {
+			if _, err := m.SendRequest(req[0], req[1]); err != ErrBlocked {
+				t.Errorf("%v: got %v; want %v", req, err, ErrBlocked)
+			}
+		}
+	})
+	/* ------------------------------------------------------------------------ */
+	t.Run("Unblock", func(t *testing.T) {
+		if err := m.Unblock("a", "b"); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Unblock("a", "b"); err != ErrNotBlocked {
+			t.Errorf("got %v; want %v", err, ErrNotBlocked)
+		}
+		if ok, _ := m.IsBlocked("a", "b"); ok {
+			t.Errorf("got %v; want %v", ok, false)
+		}
+
+		// the friendship severed by the block is not restored
+		if ok, _ := m.IsFriend("a", "b"); ok {
+			t.Errorf("got %v; want %v", ok, false)
+		}
+		if _, err := m.SendRequest("b", "a"); err != nil {
+			t.Errorf("got %v; want %v", err, nil)
+		}
+	})
+}
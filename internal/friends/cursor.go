@@ -0,0 +1,192 @@
+package friends
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Sort controls the order friends are returned in
+type Sort string
+
+const (
+	// SortName orders friends by username (a-z)
+	SortName Sort = "name"
+
+	// SortAdded orders friends by the time the friendship was made (newest first)
+	SortAdded Sort = "added"
+
+	// SortOnline orders online friends first followed by username (a-z)
+	SortOnline Sort = "online"
+)
+
+const (
+	// DefaultPageLimit used when the client does not provide a page limit
+	DefaultPageLimit = 50
+
+	// MaxPageLimit is the largest page a client may request
+	MaxPageLimit = 200
+)
+
+var (
+	// ErrBadSort returned when the requested sort is not supported
+	ErrBadSort = errors.New("bad sort")
+
+	// ErrBadCursor returned when the cursor could not be decoded or does not match
+	// the requested sort
+	ErrBadCursor = errors.New("bad cursor")
+)
+
+// ParseSort returns the Sort for the provided value defaulting to SortName
+func ParseSort(v string) (Sort, error) {
+	switch s := Sort(strings.ToLower(v)); s {
+	case "":
+		return SortName, nil
+	case SortName, SortAdded, SortOnline:
+		return s, nil
+	default:
+		return "", ErrBadSort
+	}
+}
+
+// Cursor is the position of the last friend returned in a page. Rather than an
+// offset the cursor holds the sort key of that friend, so the next page starts
+// strictly after it even when friends are added or removed between requests.
+type Cursor struct {
+	Sort Sort   `json:"s"`
+	Name string `json:"n,omitempty"`
+	Time int64  `json:"t,omitempty"`
+	Rank int    `json:"r,omitempty"`
+	BUID string `json:"b"`
+}
+
+// Encode returns the opaque cursor value handed to clients
+func (c Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor parses an opaque cursor value created by Cursor.Encode
+func DecodeCursor(v string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(v)
+	if err != nil {
+		return nil, ErrBadCursor
+	}
+	c := &Cursor{}
+	if err := json.Unmarshal(b, c); err != nil || c.BUID == "" {
+		return nil, ErrBadCursor
+	}
+	if _, err := ParseSort(string(c.Sort)); err != nil {
+		return nil, ErrBadCursor
+	}
+	return c, nil
+}
+
+// Page options used when listing friends
+type Page struct {
+	Sort   Sort
+	Limit  int
+	Cursor string
+
+	// Presence includes the account and presence status of each friend returned
+	Presence bool
+}
+
+// FriendPage is a single page of an accounts friend list
+type FriendPage struct {
+	Friends []*Friend `json:"friends"`
+	Total   int       `json:"total"`
+	Next    string    `json:"next,omitempty"`
+}
+
+// paginate sorts friends by p.Sort and returns the page following p.Cursor
+func paginate(friends []*Friend, p Page) (*FriendPage, error) {
+	if p.Limit <= 0 {
+		p.Limit = DefaultPageLimit
+	}
+	if p.Limit > MaxPageLimit {
+		p.Limit = MaxPageLimit
+	}
+	less := lessFunc(p.Sort)
+	sort.Slice(friends, func(i, j int) bool { return less(friends[i], friends[j]) })
+
+	start := 0
+	if p.Cursor != "" {
+		c, err := DecodeCursor(p.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if c.Sort != p.Sort {
+			return nil, ErrBadCursor
+		}
+		pivot := c.friend()
+		start = sort.Search(len(friends), func(i int) bool { return less(pivot, friends[i]) })
+	}
+
+	page := &FriendPage{Total: len(friends), Friends: []*Friend{}}
+	end := start + p.Limit
+	if end > len(friends) {
+		end = len(friends)
+	}
+	page.Friends = append(page.Friends, friends[start:end]...)
+	if end < len(friends) && end > start {
+		page.Next = cursorOf(friends[end-1], p.Sort).Encode()
+	}
+	return page, nil
+}
+
+// friend returns a pivot friend used to search for the cursor position
+func (c *Cursor) friend() *Friend {
+	return &Friend{
+		BUID:  c.BUID,
+		Name:  c.Name,
+		Since: time.Unix(c.Time, 0),
+		rank:  c.Rank,
+	}
+}
+
+func cursorOf(f *Friend, s Sort) Cursor {
+	c := Cursor{Sort: s, BUID: f.BUID}
+	switch s {
+	case SortAdded:
+		c.Time = f.Since.Unix()
+	case SortOnline:
+		c.Rank = f.rank
+		c.Name = f.Name
+	default:
+		c.Name = f.Name
+	}
+	return c
+}
+
+// lessFunc returns the ordering used for s. Every ordering falls back on buid
+// so that friends sharing a sort key still have a total, repeatable order.
+func lessFunc(s Sort) func(a, b *Friend) bool {
+	byName := func(a, b *Friend) bool {
+		if x, y := strings.ToLower(a.Name), strings.ToLower(b.Name); x != y {
+			return x < y
+		}
+		return a.BUID < b.BUID
+	}
+	switch s {
+	case SortAdded:
+		return func(a, b *Friend) bool {
+			if x, y := a.Since.Unix(), b.Since.Unix(); x != y {
+				return x > y
+			}
+			return a.BUID < b.BUID
+		}
+	case SortOnline:
+		return func(a, b *Friend) bool {
+			if a.rank != b.rank {
+				return a.rank < b.rank
+			}
+			return byName(a, b)
+		}
+	default:
+		return byName
+	}
+}
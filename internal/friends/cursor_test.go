@@ -0,0 +1,95 @@
+package friends
+
+import (
+	"testing"
+	"time"
+
+	"github.com/BethesdaNet/friends-go/internal/friends/status"
+)
+
+func TestCursor(t *testing.T) {
+	list := func(names ...string) []*Friend {
+		out := make([]*Friend, len(names))
+		for i, n := range names {
+			out[i] = &Friend{BUID: "buid-" + n, Name: n, Since: time.Unix(int64(1000+i), 0)}
+		}
+		return out
+	}
+
+	t.Run("Encode", func(t *testing.T) {
+		c := Cursor{Sort: SortAdded, Time: 1234, BUID: "abcd"}
+		got, err := DecodeCursor(c.Encode())
+		if err != nil {
+			t.Fatalf("got %v; want %v", err, nil)
+		}
+		if *got != c {
+			t.Errorf("got %+v; want %+v", *got, c)
+		}
+		for _, bad := range []string{"%%%", "e30", Cursor{Sort: "golf", BUID: "abcd"}.Encode()} {
+			if _, err := DecodeCursor(bad); err != ErrBadCursor {
+				t.Errorf("got %v; want %v", err, ErrBadCursor)
+			}
+		}
+	})
+	/* ------------------------------------------------------------------------ */
+	t.Run("Page", func(t *testing.T) {
+		t.Run("Name", func(t *testing.T) {
+			friends := list("echo", "alpha", "delta", "charlie", "bravo")
+			page, err := paginate(friends, Page{Sort: SortName, Limit: 2})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if page.Total != 5 || len(page.Friends) != 2 || page.Next == "" {
+				t.Fatalf("got %d/%d (%q); want %d/%d", len(page.Friends), page.Total, page.Next, 2, 5)
+			}
+			if page.Friends[0].Name != "alpha" || page.Friends[1].Name != "bravo" {
+				t.Errorf("got %s,%s; want %s,%s", page.Friends[0].Name, page.Friends[1].Name, "alpha", "bravo")
+			}
+
+			// mutate the list underneath the cursor: remove a returned friend and add
+			// one sorting before the cursor. The next page must still start at charlie.
+			friends = append(list("charlie", "delta", "echo"), list("aaron")...)
+			page, err = paginate(friends, Page{Sort: SortName, Limit: 2, Cursor: page.Next})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if page.Friends[0].Name != "charlie" || page.Friends[1].Name != "delta" {
+				t.Errorf("got %s,%s; want %s,%s", page.Friends[0].Name, page.Friends[1].Name, "charlie", "delta")
+			}
+			page, err = paginate(friends, Page{Sort: SortName, Limit: 2, Cursor: page.Next})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(page.Friends) != 1 || page.Friends[0].Name != "echo" || page.Next != "" {
+				t.Errorf("got %d (%q); want %d (%q)", len(page.Friends), page.Next, 1, "")
+			}
+		})
+		/* -------------------------------------------------------------------- */
+		t.Run("Added", func(t *testing.T) {
+			page, err := paginate(list("a", "b", "c"), Page{Sort: SortAdded, Limit: 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if page.Friends[0].Name != "c" {
+				t.Errorf("got %s; want %s", page.Friends[0].Name, "c")
+			}
+			if _, err := paginate(list("a"), Page{Sort: SortName, Cursor: page.Next}); err != ErrBadCursor {
+				t.Errorf("got %v; want %v", err, ErrBadCursor)
+			}
+		})
+		/* -------------------------------------------------------------------- */
+		t.Run("Online", func(t *testing.T) {
+			friends := list("a", "b", "c")
+			friends[0].rank = rankOf(status.Offline)
+			friends[1].rank = rankOf(status.AppearOffline)
+			friends[2].rank = rankOf(status.Online)
+			page, err := paginate(friends, Page{Sort: SortOnline})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if page.Friends[0].Name != "c" {
+				t.Errorf("got %s; want %s", page.Friends[0].Name, "c")
+			}
+		})
+	})
+}
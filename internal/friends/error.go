@@ -0,0 +1,81 @@
+package friends
+
+import (
+	"errors"
+	"net/http"
+)
+
+var (
+	// ErrBadBody returned when the request body could not be decoded
+	ErrBadBody = errors.New("bad request body")
+
+	// ErrBadBUID returned when a required buid is missing or invalid
+	ErrBadBUID = errors.New("bad buid")
+
+	// ErrSelfRequest returned when an account sends a request to itself
+	ErrSelfRequest = errors.New("cannot send a friend request to yourself")
+
+	// ErrAlreadyFriends returned when both accounts are already friends
+	ErrAlreadyFriends = errors.New("accounts are already friends")
+
+	// ErrNotFriends returned when removing a friendship that does not exist
+	ErrNotFriends = errors.New("accounts are not friends")
+
+	// ErrRequestExists returned when the same friend request is already pending
+	ErrRequestExists = errors.New("friend request already pending")
+
+	// ErrRequestPending returned when the counterpart already sent a request which
+	// should be accepted instead of sending a new one
+	ErrRequestPending = errors.New("friend request pending from recipient")
+
+	// ErrRequestNotFound returned when the friend request does not exist
+	ErrRequestNotFound = errors.New("friend request not found")
+
+	// ErrBlocked returned when either account has blocked the other. The message
+	// does not reveal which side of the block the caller is on.
+	ErrBlocked = errors.New("operation not allowed")
+
+	// ErrSelfBlock returned when an account attempts to block itself
+	ErrSelfBlock = errors.New("cannot block yourself")
+
+	// ErrNotBlocked returned when removing a block that does not exist
+	ErrNotBlocked = errors.New("account is not blocked")
+
+	// ErrMaxBlocks returned when the account has reached the block list limit
+	ErrMaxBlocks = errors.New("too many blocked accounts")
+
+	// ErrMaxRequests returned when the sender has too many outgoing requests
+	ErrMaxRequests = errors.New("too many pending friend requests")
+)
+
+// errStatus maps known errors to http status codes returned in the envelope.
+// Errors not found in the map are returned as internal server errors.
+var errStatus = map[error]int{
+	ErrUnauthenticated: http.StatusUnauthorized,
+	ErrBadHeader:       http.StatusBadRequest,
+	ErrBadBody:         http.StatusBadRequest,
+	ErrBadBUID:         http.StatusBadRequest,
+	ErrSelfRequest:     http.StatusBadRequest,
+	ErrAlreadyFriends:  http.StatusConflict,
+	ErrNotFriends:      http.StatusNotFound,
+	ErrRequestExists:   http.StatusConflict,
+	ErrRequestPending:  http.StatusConflict,
+	ErrRequestNotFound: http.StatusNotFound,
+	ErrMaxRequests:     http.StatusTooManyRequests,
+	ErrBlocked:         http.StatusForbidden,
+	ErrSelfBlock:       http.StatusBadRequest,
+	ErrNotBlocked:      http.StatusNotFound,
+	ErrMaxBlocks:       http.StatusTooManyRequests,
+	ErrMaxMultiStatus:  http.StatusBadRequest,
+	ErrBadSort:         http.StatusBadRequest,
+	ErrBadCursor:       http.StatusBadRequest,
+	ErrBadDegree:       http.StatusBadRequest,
+	ErrBadRoster:       http.StatusBadRequest,
+	ErrMaxRoster:       http.StatusBadRequest,
+	ErrNotPlayed:       http.StatusNotFound,
+	ErrNotServer:       http.StatusUnauthorized,
+	ErrNotService:      http.StatusUnauthorized,
+	ErrBadPrivacy:      http.StatusBadRequest,
+	ErrNotAccepting:    http.StatusForbidden,
+	ErrSelfMerge:       http.StatusBadRequest,
+}
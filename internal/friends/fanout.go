@@ -0,0 +1,241 @@
+package friends
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/BethesdaNet/friends-go/internal/db/redis"
+	"github.com/BethesdaNet/friends-go/internal/friends/status"
+)
+
+const (
+	// PresenceWindow is how long a presence change waits before it is announced
+	// to friends. Changes made within the window are coalesced so a reconnecting
+	// client flapping between offline and online announces nothing.
+	PresenceWindow = time.Second * 10
+
+	// FanoutBatchSize is how many friends are notified per status lookup
+	FanoutBatchSize = 100
+
+	// MaxFanoutAccounts limits how many accounts are announced per daemon tick
+	MaxFanoutAccounts = 100
+
+	// AnnouncedTTL is how long the last announced presence of an account is kept
+	AnnouncedTTL = time.Hour * 24 * 30
+)
+
+// Presence change notification titles sent to the friends of an account
+const (
+	NoteCameOnline = "Presence Status Online"
+	NoteWentDND    = "Presence Status DND"
+)
+
+// PresenceChange is the data of a presence change notification
+type PresenceChange struct {
+	BUID   string `json:"buid"`
+	Status string `json:"status"`
+}
+
+// Mute stops presence changes of target from being announced to buid
+func (m *Manager) Mute(buid, target string) error {
+	if buid == "" || target == "" {
+		return ErrBadBUID
+	}
+	ok, err := m.IsFriend(buid, target)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotFriends
+	}
+	return m.dba.SAdd(fmt.Sprintf(PaMuted, buid), target)
+}
+
+// Unmute announces presence changes of target to buid again
+func (m *Manager) Unmute(buid, target string) error {
+	if buid == "" || target == "" {
+		return ErrBadBUID
+	}
+	return m.dba.SRem(fmt.Sprintf(PaMuted, buid), target)
+}
+
+// schedulePresence schedules the presence of buid to be announced once the
+// presence window has passed. Changes made while an announcement is pending
+// share it, so an account is announced at most once per window.
+func (m *Manager) schedulePresence(buid string) error {
+	ok, err := m.dba.SetNX(fmt.Sprintf(PaPresencePending, buid), 1, PresenceWindow+DefaultDaemonInterval*2)
+	if err != nil || !ok {
+		return err
+	}
+	due := time.Now().Add(PresenceWindow)
+	return m.dba.ZAdd(KyPresenceDue, float64(due.UnixNano()), buid)
+}
+
+// fanout announces the presence of accounts whose presence window has passed.
+// It runs on every instance; removal from the due set decides which instance
+// announces an account, so announcements are spread across instances.
+func (m *Manager) fanout() {
+	due, err := m.dba.ZRangeByScore(KyPresenceDue, 0, float64(time.Now().UnixNano()), MaxFanoutAccounts)
+	if err != nil {
+		log.Printf("manager: fanout: %v", err)
+		return
+	}
+	for _, buid := range due {
+		if n, err := m.dba.ZRem(KyPresenceDue, buid); err != nil || n == 0 {
+			continue
+		}
+		if err := m.dba.Del(fmt.Sprintf(PaPresencePending, buid)); err != nil {
+			log.Printf("manager: fanout: %s: %v", buid, err)
+		}
+		if err := m.announce(buid); err != nil {
+			log.Printf("manager: fanout: %s: %v", buid, err)
+		}
+	}
+}
+
+// announce notifies the friends of buid if its presence as seen by friends
+// differs from the last presence announced. An account hiding its presence is
+// announced as offline.
+func (m *Manager) announce(buid string) error {
+	group, err := m.GetBUID(buid, "", "", "", "")
+	if err != nil {
+		return err
+	}
+	kind := presenceOf(group)
+	if p, err := m.GetPrivacy(buid); err != nil {
+		return err
+	} else if p.Presence == AudienceNobody {
+		kind = status.Offline
+	}
+
+	key := fmt.Sprintf(PaAnnounced, buid)
+	last := status.Offline
+	switch raw, err := m.dba.GetBytes(key); err {
+	case nil:
+		n, _ := strconv.Atoi(string(raw))
+		last = status.Kind(n)
+	case redis.ErrBadKey:
+	default:
+		return err
+	}
+	if kind == last {
+		return nil
+	}
+	if kind == status.Offline {
+		err = m.dba.Del(key)
+	} else {
+		err = m.dba.SetBytesExpire(key, []byte(strconv.Itoa(int(kind))), AnnouncedTTL)
+	}
+	if err != nil {
+		return err
+	}
+	return m.notifyFriends(noteFor(kind), buid, &PresenceChange{BUID: buid, Status: kind.String()})
+}
+
+// notifyFriends sends a notification to every friend of buid which is online,
+// not in do not disturb, and has not muted buid. Friends are looked up in
+// batches so large friend lists do not fetch every status at once; the statuses
+// and mute lists of a batch are each read in one round trip.
+func (m *Manager) notifyFriends(title, buid string, data interface{}) error {
+	friends, err := m.dba.SMembers(fmt.Sprintf(PaFriends, buid))
+	if err != nil || len(friends) == 0 {
+		return err
+	}
+	for i := 0; i < len(friends); i += FanoutBatchSize {
+		end := i + FanoutBatchSize
+		if end > len(friends) {
+			end = len(friends)
+		}
+		statuses, err := m.presences(friends[i:end])
+		if err != nil {
+			return err
+		}
+		online, keys := []string{}, []string{}
+		for _, id := range friends[i:end] {
+			if s, ok := statuses[id]; !ok || s.Enum <= status.AppearOffline || s.Enum == status.DND {
+				continue
+			}
+			online = append(online, id)
+			keys = append(keys, fmt.Sprintf(PaMuted, id))
+		}
+		if len(online) == 0 {
+			continue
+		}
+		muted, err := m.dba.SIsMemberAll(buid, keys...)
+		if err != nil {
+			return err
+		}
+		for j, id := range online {
+			if !muted[j] {
+				m.SendNotification(title, id, data, false)
+			}
+		}
+	}
+	return nil
+}
+
+// presences returns the status deciding the presence of each of buids as seen
+// by friends. Every status of an account is read through its key index so an
+// account present only on a product or platform is not taken for offline. The
+// statuses of all buids are read in two round trips without writing. Accounts
+// without a status deciding their presence get an offline status.
+func (m *Manager) presences(buids []string) (map[string]*Status, error) {
+	groups, err := m.groups(buids)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]*Status, len(groups))
+	for id, group := range groups {
+		if s := lead(group); s != nil {
+			out[id] = s
+		} else {
+			out[id] = (&Status{BUID: id}).Set(status.Offline)
+		}
+	}
+	return out, nil
+}
+
+// presenceOf returns the presence of an account as seen by its friends; online
+// if any status is online, otherwise dnd then idle. An account appearing offline
+// on any status is offline.
+func presenceOf(group *Group) status.Kind {
+	if s := lead(group); s != nil {
+		return s.Enum
+	}
+	return status.Offline
+}
+
+// lead returns the status deciding the presence of group as described by
+// presenceOf, or nil if the account is offline
+func lead(group *Group) *Status {
+	rank := map[status.Kind]int{status.Idle: 1, status.DND: 2, status.Online: 3}
+	var out *Status
+	for _, k := range group.Key {
+		s, ok := group.Data[k].(*Status)
+		if !ok {
+			continue
+		}
+		if s.Enum == status.AppearOffline {
+			return nil
+		}
+		if rank[s.Enum] > 0 && (out == nil || rank[s.Enum] > rank[out.Enum]) {
+			out = s
+		}
+	}
+	return out
+}
+
+// noteFor returns the notification title announcing kind
+func noteFor(kind status.Kind) string {
+	switch kind {
+	case status.Online:
+		return NoteCameOnline
+	case status.Idle:
+		return NoteWentIdle
+	case status.DND:
+		return NoteWentDND
+	}
+	return NoteWentOffline
+}
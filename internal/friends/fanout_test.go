@@ -0,0 +1,94 @@
+package friends
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/BethesdaNet/friends-go/internal/friends/status"
+)
+
+func TestFanout(t *testing.T) {
+	m, c := newTestManager()
+	for _, id := range []string{"b", "c", "d", "e"} {
+		if err := m.addFriend("a", id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.SetStatus("b", "", "", "", "", (&Status{}).Set(status.Online))
+	m.SetStatus("c", "", "", "", "", (&Status{}).Set(status.DND))
+	m.SetStatus("d", "", "", "", "", (&Status{}).Set(status.Online))
+	if err := m.Mute("d", "a"); err != nil {
+		t.Fatal(err)
+	}
+	flushPresence(m, c)
+	popNotes(m, c)
+
+	recipients := func() []string {
+		out := []string{}
+		for _, n := range popNotes(m, c) {
+			if n.Title != NotePresenceUpdate {
+				out = append(out, n.Title+":"+n.BUID)
+			}
+		}
+		sort.Strings(out)
+		return out
+	}
+
+	t.Run("Online", func(t *testing.T) {
+		m.SetStatus("a", "", "", "", "", (&Status{}).Set(status.Online))
+		flushPresence(m, c)
+		if got := recipients(); len(got) != 1 || got[0] != NoteCameOnline+":b" {
+			t.Errorf("got %v; want %v", got, NoteCameOnline+":b")
+		}
+	})
+	t.Run("Flapping", func(t *testing.T) {
+		m.DelStatus("a", "", "")
+		m.SetStatus("a", "", "", "", "", (&Status{}).Set(status.Online))
+		m.DelStatus("a", "", "")
+		m.SetStatus("a", "", "", "", "", (&Status{}).Set(status.Online))
+		if n := len(c.zsets[KyPresenceDue]); n != 1 {
+			t.Errorf("got %v; want %v", n, 1)
+		}
+		flushPresence(m, c)
+		if got := recipients(); len(got) != 0 {
+			t.Errorf("got %v; want %v", got, []string{})
+		}
+	})
+	t.Run("AppearOffline", func(t *testing.T) {
+		m.SetStatus("a", "fallout", "pc", "", "", (&Status{}).Set(status.AppearOffline))
+		flushPresence(m, c)
+		if got := recipients(); len(got) != 1 || got[0] != NoteWentOffline+":b" {
+			t.Errorf("got %v; want %v", got, NoteWentOffline+":b")
+		}
+	})
+	t.Run("Unmute", func(t *testing.T) {
+		if err := m.Unmute("d", "a"); err != nil {
+			t.Fatal(err)
+		}
+		m.DelStatus("a", "fallout", "pc")
+		flushPresence(m, c)
+		if got := recipients(); len(got) != 2 || got[1] != NoteCameOnline+":d" {
+			t.Errorf("got %v; want %v", got, NoteCameOnline+":d")
+		}
+	})
+	t.Run("Presences", func(t *testing.T) {
+		m.SetStatus("e", "fallout", "pc", "", "", (&Status{}).Set(status.DND))
+		key := status.Key("b", "", "", false)
+		c.Del(key)
+		out, err := m.presences([]string{"b", "c", "e", "x"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for id, want := range map[string]status.Kind{"b": status.Offline, "c": status.DND, "e": status.DND, "x": status.Offline} {
+			if s := out[id]; s == nil || s.Enum != want {
+				t.Errorf("%s: got %v; want %v", id, s, want)
+			}
+		}
+
+		// reads of many accounts leave expired keys in the index
+		if ok, _ := m.dba.SIsMember(fmt.Sprintf(status.PaKeys, "b"), key); !ok {
+			t.Errorf("got %v; want %v", ok, true)
+		}
+	})
+}
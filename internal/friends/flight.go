@@ -0,0 +1,50 @@
+package friends
+
+import "sync"
+
+// flight coalesces concurrent lookups by key so that a key being fetched by one
+// caller is waited on by every other caller instead of being fetched again.
+type flight struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+// call is an in-flight or completed lookup of a single key
+type call struct {
+	done chan struct{}
+	val  interface{}
+	err  error
+}
+
+// join returns the keys the caller now owns and must resolve with finish, and
+// the calls already in-flight for the remaining keys.
+func (g *flight) join(keys []string) (own []string, wait map[string]*call) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.calls == nil {
+		g.calls = make(map[string]*call)
+	}
+	wait = make(map[string]*call)
+	for _, k := range keys {
+		if c, ok := g.calls[k]; ok {
+			wait[k] = c
+			continue
+		}
+		g.calls[k] = &call{done: make(chan struct{})}
+		own = append(own, k)
+	}
+	return own, wait
+}
+
+// finish resolves an owned key, waking every caller waiting on it
+func (g *flight) finish(key string, val interface{}, err error) {
+	g.mu.Lock()
+	c, ok := g.calls[key]
+	delete(g.calls, key)
+	g.mu.Unlock()
+	if !ok {
+		return
+	}
+	c.val, c.err = val, err
+	close(c.done)
+}
@@ -0,0 +1,173 @@
+package friends
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/BethesdaNet/friends-go/internal/friends/status"
+)
+
+// Friend is a single entry in an accounts friend list
+type Friend struct {
+	BUID  string    `json:"buid"`
+	Name  string    `json:"username"`
+	Since time.Time `json:"since"`
+
+	// Account and Presence are only set when requested with Page.Presence
+	Account  *Account `json:"account,omitempty"`
+	Presence *Status  `json:"presence,omitempty"`
+
+	// rank orders friends by presence when sorting online first
+	rank int
+}
+
+// GetFriends returns a page of the friend list of buid
+func (m *Manager) GetFriends(buid string, p Page) (*FriendPage, error) {
+	if buid == "" {
+		return nil, ErrBadBUID
+	}
+	friends, err := m.friends(buid)
+	if err != nil {
+		return nil, err
+	}
+	return m.page(buid, friends, p)
+}
+
+// page sorts friends and returns the page p as seen by viewer resolving account
+// data as needed
+func (m *Manager) page(viewer string, friends []*Friend, p Page) (*FriendPage, error) {
+	// the page must be sorted before the range of friends is known, so names are
+	// resolved for every friend unless sorting by date where only the returned
+	// page needs them
+	var (
+		accounts map[string]*Account
+		statuses map[string]*Status
+		err      error
+	)
+	switch p.Sort {
+	case SortOnline:
+		if statuses, err = m.rank(viewer, friends); err != nil {
+			return nil, err
+		}
+		accounts = m.names(friends)
+	case SortName:
+		accounts = m.names(friends)
+	}
+
+	page, err := paginate(friends, p)
+	if err != nil {
+		return nil, err
+	}
+	if p.Sort == SortAdded {
+		accounts = m.names(page.Friends)
+	}
+	if p.Presence {
+		if err := m.present(viewer, page.Friends, accounts, statuses); err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+// present sets the account and the status deciding the presence of each friend.
+// Statuses already fetched while ranking are reused, otherwise they are fetched
+// for the friends of the page.
+func (m *Manager) present(viewer string, friends []*Friend, accounts map[string]*Account, statuses map[string]*Status) error {
+	if statuses == nil {
+		var err error
+		if statuses, err = m.presences(buidsOf(friends)); err != nil {
+			return err
+		}
+		if err := m.conceal(viewer, statuses); err != nil {
+			return err
+		}
+	}
+	for _, f := range friends {
+		f.Account = accounts[f.BUID]
+		if s, ok := statuses[f.BUID]; ok {
+			f.Presence = s
+		} else {
+			f.Presence = (&Status{BUID: f.BUID}).Set(status.Offline)
+		}
+	}
+	return nil
+}
+
+// buidsOf returns the buids of friends
+func buidsOf(friends []*Friend) []string {
+	out := make([]string, len(friends))
+	for i, f := range friends {
+		out[i] = f.BUID
+	}
+	return out
+}
+
+// friends returns the unsorted friend list of buid without account data
+func (m *Manager) friends(buid string) ([]*Friend, error) {
+	since, err := m.dba.HGetAll(fmt.Sprintf(PaFriendsSince, buid))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*Friend, 0, len(since))
+	for id, ts := range since {
+		sec, _ := strconv.ParseInt(ts, 10, 64)
+		out = append(out, &Friend{BUID: id, Since: time.Unix(sec, 0).UTC()})
+	}
+	return out, nil
+}
+
+// names resolves usernames from the identity provider and returns the accounts
+// found. Lookup failures are not fatal; friends missing from identity are
+// returned without a name.
+func (m *Manager) names(friends []*Friend) map[string]*Account {
+	if len(friends) == 0 || m.identity == nil {
+		return nil
+	}
+	accounts, err := m.GetAccounts(buidsOf(friends)...)
+	if err != nil {
+		log.Printf("manager: names: %v", err)
+	}
+	for _, f := range friends {
+		if a, ok := accounts[f.BUID]; ok {
+			f.Name = a.Name
+		}
+	}
+	return accounts
+}
+
+// rank sets the presence rank of each friend from every status they are present
+// on and returns the statuses deciding their presence. Friends appearing offline
+// rank the same as offline friends.
+func (m *Manager) rank(viewer string, friends []*Friend) (map[string]*Status, error) {
+	if len(friends) == 0 {
+		return nil, nil
+	}
+	statuses, err := m.presences(buidsOf(friends))
+	if err != nil {
+		return nil, err
+	}
+	if err := m.conceal(viewer, statuses); err != nil {
+		return nil, err
+	}
+	for _, f := range friends {
+		if s, ok := statuses[f.BUID]; ok {
+			f.rank = rankOf(s.Enum)
+		}
+	}
+	return statuses, nil
+}
+
+func rankOf(k status.Kind) int {
+	switch k {
+	case status.Online:
+		return 0
+	case status.Idle:
+		return 1
+	case status.DND:
+		return 2
+	default:
+		return 3
+	}
+}
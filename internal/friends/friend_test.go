@@ -0,0 +1,48 @@
+package friends
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/BethesdaNet/friends-go/internal/friends/status"
+)
+
+func TestFriendsPresence(t *testing.T) {
+	m, _ := newTestManager()
+	for _, id := range []string{"b", "c", "d"} {
+		if err := m.addFriend("a", id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.SetStatus("b", "", "", "", "", (&Status{}).Set(status.Online))
+	m.SetStatus("c", "", "", "", "", (&Status{}).Set(status.AppearOffline))
+	m.SetStatus("d", "fallout", "pc", "", "", (&Status{}).Set(status.DND))
+
+	page, err := m.GetFriends("a", Page{Sort: SortOnline, Presence: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Friends) != 3 || page.Friends[0].BUID != "b" || page.Friends[1].BUID != "d" {
+		t.Fatalf("got %+v; want %v first", page.Friends, "b,d")
+	}
+	for _, f := range page.Friends {
+		if f.Presence == nil {
+			t.Fatalf("got %v; want presence", f.Presence)
+		}
+		b, err := json.Marshal(f.Presence)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := `"status":"offline"`
+		switch f.BUID {
+		case "b":
+			want = `"status":"online"`
+		case "d":
+			want = `"status":"dnd"`
+		}
+		if !strings.Contains(string(b), want) {
+			t.Errorf("got %s; want %s", b, want)
+		}
+	}
+}
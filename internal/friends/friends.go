@@ -3,11 +3,15 @@ package friends
 import (
 	"context"
 	"encoding/gob"
+	"log"
 	"os"
+	"time"
 
+	"github.com/BethesdaNet/friends-go/internal/client"
 	"github.com/BethesdaNet/friends-go/internal/db/redis"
 	"github.com/BethesdaNet/friends-go/internal/metric/bio"
 	"github.com/BethesdaNet/friends-go/internal/metric/relic"
+	"github.com/BethesdaNet/friends-go/internal/platform"
 	"github.com/BethesdaNet/friends-go/internal/provider"
 )
 
@@ -18,7 +22,7 @@ const (
 	DisableSplunkLogging = false
 
 	// DefaultRedisTTL is how long the record should persist in redis
-	DefaultRedisTTL = 18600
+	DefaultRedisTTL = time.Second * 18600
 
 	// APIVersion specifies the allowed api version on request
 	APIVersion = "v1"
@@ -43,10 +47,37 @@ func Open(conf Config, dba *redis.Agent, nra *relic.Agent) (*Friends, error) {
 	// create manager instance and set relic db agent. NOTE(xc): create "new" func
 	// to handle creation of the manager if any new complexities (maps, cache) are
 	// implemented in the future.
+	if conf.AccountCacheSize <= 0 {
+		conf.AccountCacheSize = DefaultAccountCacheSize
+	}
+	if conf.AccountCacheTTL <= 0 {
+		conf.AccountCacheTTL = DefaultAccountCacheTTL
+	}
+	if conf.RequestTTL <= 0 {
+		conf.RequestTTL = DefaultRequestTTL
+	}
 	f.manager = &Manager{
-		dba: dba,
-		//notes: make(chan Notification),
-		done: make(chan struct{}),
+		dba:      dba,
+		account:  client.NewLRU(conf.AccountCacheSize, conf.AccountCacheTTL),
+		breakers: make(map[string]*provider.Breaker),
+		breaker:  conf.Breaker,
+		done:     make(chan struct{}),
+
+		lease:         redis.NewLease(dba, KyLeader, leaseTTL(conf.LeaseTTL)),
+		idleThreshold: conf.IdleThreshold,
+		requestTTL:    conf.RequestTTL,
+		playedWindow:  conf.PlayedWindow,
+		noteWindow:    conf.NoteWindow,
+	}
+
+	// verify bnet keys locally when a key set is configured, otherwise the sidecar
+	// is trusted to have verified the key and set the decoded headers
+	if conf.KeySet.Path != "" {
+		v, err := platform.NewVerifier(conf.KeySet)
+		if err != nil {
+			return nil, err
+		}
+		f.verifier = v
 	}
 
 	// create new logger and redirect it to stderr or the wanted pipe output
@@ -87,6 +118,9 @@ type Friends struct {
 	// volume and noise.
 	Log *bio.Logger
 
+	// verifier decodes and verifies bnet keys when a key set is configured
+	verifier *platform.Verifier
+
 	// nra wraps newrelics agent to handle error cases where the default agent panics
 	// when invalid keys are set causing fatal tasks
 	nra *relic.Agent
@@ -103,15 +137,54 @@ type Config struct {
 	Target string `json:"target"`
 
 	// Private changes target to contain the "-private" prefix for the task and
-	// modifies the behavior of the presence service.
+	// modifies the behavior of the presence service. The /private routes are only
+	// served by private tasks.
 	Private bool `json:"private"`
 
+	// ServerKey is the key game servers send in HeaderKeyServer when no key set is
+	// configured. Server routes reject every call if neither is set.
+	ServerKey string `json:"server_key"`
+
+	// ServiceKey is the key other platform services send in HeaderKeyMaster to
+	// call the /private routes. Private routes reject every call if it is empty.
+	ServiceKey string `json:"service_key"`
+
 	// Redis contains settings for redis agent
 	Redis redis.Config
 
 	// Relic contains settings for newrelic agent
 	Relic relic.Config
 
+	// KeySet contains settings for local bnet key verification. If the key set path
+	// is empty keys are expected to be verified by the sidecar.
+	KeySet platform.VerifierConfig `json:"key_set"`
+
+	// AccountCacheSize limits how many identity accounts are cached in memory
+	AccountCacheSize int `json:"account_cache_size"`
+
+	// AccountCacheTTL is how long a cached account is served before refreshing
+	AccountCacheTTL time.Duration `json:"account_cache_ttl"`
+
+	// Breaker contains settings for the circuit breaker wrapping each provider
+	Breaker provider.BreakerConfig `json:"breaker"`
+
+	// LeaseTTL is how long the leader lease lasts without renewal and so how long
+	// singleton daemon jobs stop for when the leader dies
+	LeaseTTL time.Duration `json:"lease_ttl"`
+
+	// IdleThreshold is how long an online account may be inactive before it is
+	// moved to idle
+	IdleThreshold time.Duration `json:"idle_threshold"`
+
+	// RequestTTL is how long a friend request stays pending before it expires
+	RequestTTL time.Duration `json:"request_ttl"`
+
+	// PlayedWindow is how long accounts are kept in recently played lists
+	PlayedWindow time.Duration `json:"played_window"`
+
+	// NoteWindow is how long notifications are held to be batched per recipient
+	NoteWindow time.Duration `json:"note_window"`
+
 	// Provider map holds onto provider configurations by name
 	Provider map[string]interface{} `json:"provider"`
 
@@ -128,6 +201,8 @@ type Config struct {
 
 // Close the presence service
 func (f *Friends) Close() {
+	f.manager.drain(DefaultDrainTimeout)
+	f.manager.resign()
 	close(f.manager.done)
 	close(f.done)
 }
@@ -152,6 +227,13 @@ func (f *Friends) init() error {
 	}
 	if EnableDaemon {
 		go f.manager.run()
+		f.manager.work(DefaultNoteWorkers)
+
+		// expired keys are still removed by redis if the subscription fails, only
+		// their cleanup is deferred until the indexes are next read
+		if err := f.manager.listen(); err != nil {
+			log.Printf("friends: keyspace: %v", err)
+		}
 	}
 	return nil
 }
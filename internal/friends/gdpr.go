@@ -0,0 +1,465 @@
+package friends
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/BethesdaNet/friends-go/internal/friends/status"
+)
+
+// KyAudit is the list erasure audit records are kept in, newest first
+const KyAudit = "friends.audit"
+
+// AuditErase is the action of an erasure audit record
+const AuditErase = "erase"
+
+// notePage is how many queued notifications are read at a time when looking for
+// the notifications of an account
+const notePage = 1000
+
+// Export is every record the service stores about an account for a data
+// subject request. Accounts which blocked the account are left out as that
+// is data about the other accounts.
+type Export struct {
+	BUID     string    `json:"buid"`
+	Time     time.Time `json:"time"`
+	Friends  []*Friend `json:"friends"`
+	Requests *Requests `json:"requests"`
+	Blocks   []string  `json:"blocks"`
+	Settings *Settings `json:"settings"`
+	Statuses []*Status `json:"statuses"`
+	Played   []*Played `json:"played"`
+	Products []string  `json:"products"`
+
+	// Notifications waiting to be delivered, retried, or kept as dead letters
+	// which are sent to or mention the account
+	Notifications []*Queued `json:"notifications"`
+}
+
+// Settings groups the settings of an account in an export
+type Settings struct {
+	Privacy   *Privacy `json:"privacy"`
+	Muted     []string `json:"muted"`
+	Dismissed []string `json:"dismissed_suggestions"`
+}
+
+// Audit records an erasure. It holds no data about the account beyond the buid
+// and how many records were removed.
+type Audit struct {
+	ID        string         `json:"id"`
+	Action    string         `json:"action"`
+	BUID      string         `json:"buid"`
+	Reference string         `json:"reference,omitempty"`
+	Removed   map[string]int `json:"removed"`
+	Time      time.Time      `json:"time"`
+}
+
+// Export returns every record stored about buid
+func (m *Manager) Export(buid string) (*Export, error) {
+	if buid == "" {
+		return nil, ErrBadBUID
+	}
+	out := &Export{BUID: buid, Time: time.Now().UTC(), Settings: &Settings{}}
+	var err error
+	if out.Friends, err = m.friends(buid); err != nil {
+		return nil, err
+	}
+	if out.Requests, err = m.GetRequests(buid); err != nil {
+		return nil, err
+	}
+	if out.Settings.Privacy, err = m.GetPrivacy(buid); err != nil {
+		return nil, err
+	}
+	if out.Played, err = m.recent(buid); err != nil {
+		return nil, err
+	}
+	for _, set := range []struct {
+		pattern string
+		out     *[]string
+	}{
+		{PaBlocks, &out.Blocks},
+		{PaMuted, &out.Settings.Muted},
+		{PaDismissed, &out.Settings.Dismissed},
+		{PaProducts, &out.Products},
+	} {
+		if *set.out, err = m.dba.SMembers(fmt.Sprintf(set.pattern, buid)); err != nil {
+			return nil, err
+		}
+	}
+
+	group, err := m.GetBUID(buid, "", "", "", "")
+	if err != nil {
+		return nil, err
+	}
+	out.Statuses = make([]*Status, 0, len(group.Key))
+	for _, k := range group.Key {
+		if s, ok := group.Data[k].(*Status); ok && s.Enum != status.Offline {
+			out.Statuses = append(out.Statuses, s)
+		}
+	}
+
+	linked := []string{buid}
+	for _, f := range out.Friends {
+		linked = append(linked, f.BUID)
+	}
+	for _, req := range out.Requests.Incoming {
+		linked = append(linked, req.From)
+	}
+	for _, req := range out.Requests.Outgoing {
+		linked = append(linked, req.To)
+	}
+	batches, err := m.batchedAbout(buid, linked)
+	if err != nil {
+		return nil, err
+	}
+	held, err := m.notesAbout(buid)
+	if err != nil {
+		return nil, err
+	}
+	rows := []string{}
+	for _, batch := range batches {
+		for _, raw := range batch {
+			rows = append(rows, raw)
+		}
+	}
+	for _, list := range held {
+		rows = append(rows, list...)
+	}
+	out.Notifications = make([]*Queued, 0, len(rows))
+	for _, raw := range rows {
+		q := &Queued{}
+		if err := json.Unmarshal([]byte(raw), q); err == nil {
+			out.Notifications = append(out.Notifications, q)
+		}
+	}
+	return out, nil
+}
+
+// Erase removes every record stored about buid, including the edges stored
+// under the keys of the accounts it is connected to, and returns the audit
+// record kept for the erasure. Notifications sent to or mentioning buid are
+// removed wherever they wait to be delivered. Stored suggestions are not
+// indexed by the accounts suggested, so the suggestions of every account buid
+// could have been suggested to are dropped and computed again.
+func (m *Manager) Erase(buid, reference string) (*Audit, error) {
+	if buid == "" {
+		return nil, ErrBadBUID
+	}
+	removed := map[string]int{}
+
+	friends, err := m.dba.SMembers(fmt.Sprintf(PaFriends, buid))
+	if err != nil {
+		return nil, err
+	}
+	stale := map[string]bool{}
+	for _, id := range friends {
+		ids, err := m.dba.SMembers(fmt.Sprintf(PaFriends, id))
+		if err != nil {
+			return nil, err
+		}
+		for _, other := range ids {
+			stale[other] = true
+		}
+	}
+	for _, id := range friends {
+		if err := m.dba.SRem(fmt.Sprintf(PaFriends, id), buid); err != nil {
+			return nil, err
+		}
+		if err := m.dba.HDel(fmt.Sprintf(PaFriendsSince, id), buid); err != nil {
+			return nil, err
+		}
+		if err := m.dba.SRem(fmt.Sprintf(PaMuted, id), buid); err != nil {
+			return nil, err
+		}
+	}
+	removed["friends"] = len(friends)
+
+	// notifications about buid are only sent to its friends and the accounts on
+	// the other side of its requests
+	linked := make([]string, 0, len(friends))
+	linked = append(linked, friends...)
+
+	for _, dir := range []struct {
+		pattern string
+		del     func(id string) error
+	}{
+		{PaRequestsIn, func(id string) error { return m.delRequest(buid, id) }},
+		{PaRequestsOut, func(id string) error { return m.delRequest(id, buid) }},
+	} {
+		ids, err := m.dba.SMembers(fmt.Sprintf(dir.pattern, buid))
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			if err := dir.del(id); err != nil {
+				return nil, err
+			}
+		}
+		linked = append(linked, ids...)
+		removed["requests"] += len(ids)
+	}
+
+	// buid is removed from the block lists of accounts which blocked it as well,
+	// an erased account can not send requests for the block to stop
+	for _, edge := range []struct {
+		pattern, reverse string
+	}{
+		{PaBlocks, PaBlockedBy},
+		{PaBlockedBy, PaBlocks},
+	} {
+		ids, err := m.dba.SMembers(fmt.Sprintf(edge.pattern, buid))
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			if err := m.dba.SRem(fmt.Sprintf(edge.reverse, id), buid); err != nil {
+				return nil, err
+			}
+		}
+		removed["blocks"] += len(ids)
+	}
+
+	played, err := m.dba.HGetAll(fmt.Sprintf(PaPlayed, buid))
+	if err != nil {
+		return nil, err
+	}
+	for id := range played {
+		if err := m.dba.HDel(fmt.Sprintf(PaPlayed, id), buid); err != nil {
+			return nil, err
+		}
+		stale[id] = true
+	}
+	removed["played"] = len(played)
+
+	delete(stale, buid)
+	for id := range stale {
+		if err := m.dba.Del(fmt.Sprintf(PaSuggestions, id)); err != nil {
+			return nil, err
+		}
+	}
+
+	n, err := m.eraseKeys(buid)
+	if err != nil {
+		return nil, err
+	}
+	removed["keys"] = n
+
+	// batches are purged first as they are queued once their window passes
+	if removed["notifications"], err = m.eraseNotes(buid, linked); err != nil {
+		return nil, err
+	}
+	m.Invalidate(buid)
+
+	audit := &Audit{ID: noteID(), Action: AuditErase, BUID: buid, Reference: reference, Removed: removed, Time: time.Now().UTC()}
+	b, _ := json.Marshal(audit)
+	if _, err := m.dba.LPush(KyAudit, string(b)); err != nil {
+		return nil, err
+	}
+	log.Printf("manager: erase: %s %s: removed %v", audit.ID, buid, removed)
+	return audit, nil
+}
+
+// eraseKeys deletes the keys owned by buid and removes it from the shared
+// daemon sets, returning how many keys existed
+func (m *Manager) eraseKeys(buid string) (int, error) {
+	statuses, err := m.dba.SMembers(fmt.Sprintf(status.PaKeys, buid))
+	if err != nil {
+		return 0, err
+	}
+	keys := []string{fmt.Sprintf(status.PaKeys, buid), fmt.Sprintf(status.KyBUIDLastActivity, buid)}
+	for _, k := range statuses {
+		keys = append(keys, k, k+status.SxLastActivity)
+	}
+	for _, pattern := range []string{
+		PaAnnounced, PaBlocks, PaBlockedBy, PaDismissed, PaFriends, PaFriendsSince,
+		PaMuted, PaNoteBatch, PaNoteBatchWait, PaPlayed, PaPresencePending, PaPrivacy,
+		PaProducts, PaRequestsIn, PaRequestsOut, PaSuggestions,
+	} {
+		keys = append(keys, fmt.Sprintf(pattern, buid))
+	}
+	n := 0
+	for _, k := range keys {
+		ok, err := m.dba.Exists(k)
+		if err != nil {
+			return n, err
+		}
+		if ok {
+			n++
+		}
+	}
+	if err := m.dba.Del(keys...); err != nil {
+		return n, err
+	}
+
+	if err := m.dba.SRem(fmt.Sprintf(PaOnline, shardOf(buid)), buid); err != nil {
+		return n, err
+	}
+	if err := m.dba.SRem(KySuggestQueue, buid); err != nil {
+		return n, err
+	}
+	for _, key := range []string{KyPresenceDue, KyNoteBatchDue, KySuggestRefresh} {
+		if _, err := m.dba.ZRem(key, buid); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// eraseNotes removes the notifications sent to or mentioning buid from the
+// batches held for linked accounts and from the queue, retry set, dead letter
+// list, and working lists, returning how many were removed. A batch mentioning
+// buid is removed whole. Notifications being delivered while this runs are sent.
+func (m *Manager) eraseNotes(buid string, linked []string) (int, error) {
+	batches, err := m.batchedAbout(buid, linked)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for key, fields := range batches {
+		removed, err := m.dba.HDelIf(key, fields)
+		if err != nil {
+			return n, err
+		}
+		n += int(removed)
+	}
+
+	held, err := m.notesAbout(buid)
+	if err != nil {
+		return n, err
+	}
+	for key, rows := range held {
+		for _, raw := range rows {
+			if key == KyNoteRetry {
+				removed, err := m.dba.ZRem(key, raw)
+				if err != nil {
+					return n, err
+				}
+				n += int(removed)
+				continue
+			}
+			if err := m.dba.LRem(key, 1, raw); err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+	return n, nil
+}
+
+// batchedAbout returns the notifications sent to or mentioning buid held in the
+// batches of linked accounts by batch key and field
+func (m *Manager) batchedAbout(buid string, linked []string) (map[string]map[string]string, error) {
+	out := map[string]map[string]string{}
+	for _, id := range linked {
+		key := fmt.Sprintf(PaNoteBatch, id)
+		rows, err := m.dba.HGetAll(key)
+		if err != nil {
+			return nil, err
+		}
+		for field, raw := range rows {
+			if !mentions(raw, buid) {
+				continue
+			}
+			if out[key] == nil {
+				out[key] = map[string]string{}
+			}
+			out[key][field] = raw
+		}
+	}
+	return out, nil
+}
+
+// notesAbout returns the notifications sent to or mentioning buid held in the
+// queue, retry set, dead letter list, and working lists by the key holding them
+func (m *Manager) notesAbout(buid string) (map[string][]string, error) {
+	out := map[string][]string{}
+	retries, err := m.dba.ZRangeByScore(KyNoteRetry, 0, math.Inf(1), 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, raw := range retries {
+		if mentions(raw, buid) {
+			out[KyNoteRetry] = append(out[KyNoteRetry], raw)
+		}
+	}
+
+	owners, err := m.dba.SMembers(KyNoteWorkers)
+	if err != nil {
+		return nil, err
+	}
+	lists := []string{KyNoteQueue, KyNoteDead}
+	for _, owner := range owners {
+		lists = append(lists, fmt.Sprintf(PaNoteWorking, owner))
+	}
+	for _, key := range lists {
+		for start := int64(0); ; start += notePage {
+			rows, err := m.dba.LRange(key, start, start+notePage-1)
+			if err != nil {
+				return nil, err
+			}
+			for _, raw := range rows {
+				if mentions(raw, buid) {
+					out[key] = append(out[key], raw)
+				}
+			}
+			if len(rows) < notePage {
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+// mentions returns true if the queued notification raw is sent to buid or holds
+// it anywhere in its data. Notifications which cannot be decoded are matched on
+// their text.
+func mentions(raw, buid string) bool {
+	q := &Queued{}
+	if err := json.Unmarshal([]byte(raw), q); err != nil {
+		return strings.Contains(raw, buid)
+	}
+	return q.mentions(buid)
+}
+
+// mentions returns true if q or any notification of its batch is sent to buid or
+// holds it in its data
+func (q *Queued) mentions(buid string) bool {
+	if q.BUID == buid {
+		return true
+	}
+	for _, n := range q.Batch {
+		if n.mentions(buid) {
+			return true
+		}
+	}
+	var data interface{}
+	if err := json.Unmarshal(q.Data, &data); err != nil {
+		return false
+	}
+	return holds(data, buid)
+}
+
+// holds returns true if the decoded json value v is or contains the string s
+func holds(v interface{}, s string) bool {
+	switch v := v.(type) {
+	case string:
+		return v == s
+	case []interface{}:
+		for _, e := range v {
+			if holds(e, s) {
+				return true
+			}
+		}
+	case map[string]interface{}:
+		for _, e := range v {
+			if holds(e, s) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package friends
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BethesdaNet/friends-go/internal/friends/status"
+)
+
+func TestErase(t *testing.T) {
+	m, c := newTestManager()
+	m.playedWindow = time.Hour
+	for _, id := range []string{"a", "b"} {
+		if err := m.addFriend("o", id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, req := range [][2]string{{"c", "o"}, {"o", "d"}} {
+		if _, err := m.SendRequest(req[0], req[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.Block("o", "x")
+	m.Block("y", "o")
+	m.Mute("a", "o")
+	m.SetPrivacy("o", &Privacy{Requests: AudienceNobody, Presence: AudienceFriends})
+	m.AddRoster(Roster{Product: "fallout", Platform: "pc", BUIDs: []string{"o", "p"}})
+	if err := m.SetStatus("o", "fallout", "pc", "", "", (&Status{}).Set(status.Online)); err != nil {
+		t.Fatal(err)
+	}
+	flushNotes(m, c)
+	for _, q := range []*Queued{
+		{ID: "1", BUID: "z", Data: json.RawMessage(`{"from":"o","to":"z"}`)},
+		{ID: "2", BUID: "z", Data: json.RawMessage(`{"from":"q","to":"z"}`)},
+	} {
+		b, _ := json.Marshal(q)
+		c.LPush(KyNoteDead, string(b))
+	}
+	m.batch(&Queued{ID: "3", BUID: "a", Data: json.RawMessage(`{"buid":"o"}`)}, "presence.o")
+
+	t.Run("Export", func(t *testing.T) {
+		out, err := m.Export("o")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(out.Friends) != 2 || len(out.Requests.Incoming) != 1 || len(out.Requests.Outgoing) != 1 {
+			t.Errorf("got %+v; want %v friends, %v in, %v out", out, 2, 1, 1)
+		}
+		if len(out.Blocks) != 1 || out.Settings.Privacy.Requests != AudienceNobody {
+			t.Errorf("got %v %+v; want %v %v", out.Blocks, out.Settings.Privacy, "x", AudienceNobody)
+		}
+		if len(out.Played) != 1 || len(out.Statuses) != 1 {
+			t.Errorf("got %v played, %v statuses; want %v, %v", len(out.Played), len(out.Statuses), 1, 1)
+		}
+		if len(out.Notifications) != 4 {
+			t.Errorf("got %v; want %v", len(out.Notifications), 4)
+		}
+	})
+	/* ------------------------------------------------------------------------ */
+	t.Run("Erase", func(t *testing.T) {
+		audit, err := m.Erase("o", "ticket-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if audit.Removed["friends"] != 2 || audit.Removed["requests"] != 2 || audit.Removed["blocks"] != 2 {
+			t.Errorf("got %v; want %v friends, %v requests, %v blocks", audit.Removed, 2, 2, 2)
+		}
+		for _, edge := range []struct{ pattern, buid string }{
+			{PaFriends, "a"}, {PaFriends, "b"}, {PaMuted, "a"}, {PaRequestsOut, "c"},
+			{PaRequestsIn, "d"}, {PaBlockedBy, "x"}, {PaBlocks, "y"},
+		} {
+			if ok, _ := m.dba.SIsMember(fmt.Sprintf(edge.pattern, edge.buid), "o"); ok {
+				t.Errorf("%v: got %v; want %v", edge, ok, false)
+			}
+		}
+		if rows, _ := m.dba.HGetAll(fmt.Sprintf(PaPlayed, "p")); len(rows) != 0 {
+			t.Errorf("got %v; want none", rows)
+		}
+		for _, pattern := range []string{PaFriends, PaBlocks, PaPrivacy, PaPlayed, status.PaKeys} {
+			if ok, _ := m.dba.Exists(fmt.Sprintf(pattern, "o")); ok {
+				t.Errorf("%v: got %v; want %v", pattern, ok, false)
+			}
+		}
+		if rows, _ := c.LRange(KyAudit, 0, -1).Result(); len(rows) != 1 || strings.Contains(rows[0], "fallout") {
+			t.Errorf("got %v; want one audit record", rows)
+		}
+	})
+	/* ------------------------------------------------------------------------ */
+	t.Run("Notifications", func(t *testing.T) {
+		if held, _ := m.notesAbout("o"); len(held) != 0 {
+			t.Errorf("got %v; want none", held)
+		}
+		if rows, _ := c.HGetAll(fmt.Sprintf(PaNoteBatch, "a")).Result(); len(rows) != 0 {
+			t.Errorf("got %v; want none", rows)
+		}
+		if rows, _ := c.LRange(KyNoteDead, 0, -1).Result(); len(rows) != 1 || !strings.Contains(rows[0], `"q"`) {
+			t.Errorf("got %v; want %v", rows, "the dead letter not mentioning o")
+		}
+	})
+}
@@ -0,0 +1,107 @@
+package friends
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi"
+)
+
+// InvalidateBody is the inbound json body listing accounts to invalidate
+type InvalidateBody struct {
+	BUIDs []string `json:"buids"`
+}
+
+// MaxInvalidateBUIDs limits how many accounts may be invalidated per request
+const MaxInvalidateBUIDs = 1000
+
+// InvalidateAccounts removes cached identity accounts. Identity calls this when
+// an account was merged or its username changed.
+func (f *Friends) InvalidateAccounts(w http.ResponseWriter, r *http.Request) {
+	body := InvalidateBody{}
+	if err := decode(r, &body); err != nil {
+		f.fail(w, err)
+		return
+	}
+	if len(body.BUIDs) == 0 || len(body.BUIDs) > MaxInvalidateBUIDs {
+		f.fail(w, ErrBadBody)
+		return
+	}
+	for _, id := range body.BUIDs {
+		if !ValidBUID(id) {
+			f.fail(w, ErrBadBUID)
+			return
+		}
+	}
+	f.manager.Invalidate(body.BUIDs...)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MergeBody is the inbound json body of an account merge
+type MergeBody struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// MergeAccounts moves the friends, requests, blocks, and settings of a merged
+// account onto the surviving account. Identity must call this with the service
+// key once it merged the account, as lookups of a merged account do not name the
+// surviving account; calling it again after a failure finishes the merge.
+func (f *Friends) MergeAccounts(w http.ResponseWriter, r *http.Request) {
+	body := MergeBody{}
+	if err := decode(r, &body); err != nil {
+		f.fail(w, err)
+		return
+	}
+	if !ValidBUID(body.From) || !ValidBUID(body.To) {
+		f.fail(w, ErrBadBUID)
+		return
+	}
+	if err := f.manager.Merge(body.From, body.To); err != nil {
+		f.fail(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ExportAccount returns every record stored about {buid} for a data subject
+// access request
+func (f *Friends) ExportAccount(w http.ResponseWriter, r *http.Request) {
+	buid := chi.URLParam(r, "buid")
+	if !ValidBUID(buid) {
+		f.fail(w, ErrBadBUID)
+		return
+	}
+	out, err := f.manager.Export(buid)
+	if err != nil {
+		f.fail(w, err)
+		return
+	}
+	f.reply(w, http.StatusOK, out)
+}
+
+// EraseBody is the inbound json body of an account erasure. Reference is kept
+// in the audit record to tie the erasure to the request it fulfils.
+type EraseBody struct {
+	Reference string `json:"reference"`
+}
+
+// EraseAccount removes every record stored about {buid}, including the edges
+// stored under the accounts it is connected to, and returns the audit record
+func (f *Friends) EraseAccount(w http.ResponseWriter, r *http.Request) {
+	buid := chi.URLParam(r, "buid")
+	if !ValidBUID(buid) {
+		f.fail(w, ErrBadBUID)
+		return
+	}
+	body := EraseBody{}
+	if err := decode(r, &body); err != nil {
+		f.fail(w, err)
+		return
+	}
+	audit, err := f.manager.Erase(buid, body.Reference)
+	if err != nil {
+		f.fail(w, err)
+		return
+	}
+	f.reply(w, http.StatusOK, audit)
+}
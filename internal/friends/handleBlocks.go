@@ -0,0 +1,52 @@
+package friends
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi"
+)
+
+// GetBlocks returns the accounts blocked by the caller
+func (f *Friends) GetBlocks(w http.ResponseWriter, r *http.Request) {
+	state := GetState(r)
+	data, err := f.manager.GetBlocks(state.BUID)
+	if err != nil {
+		f.fail(w, err)
+		return
+	}
+	f.reply(w, http.StatusOK, data)
+}
+
+// Block adds the buid in the body to the callers block list
+func (f *Friends) Block(w http.ResponseWriter, r *http.Request) {
+	state := GetState(r)
+	body := RequestBody{}
+	if err := decode(r, &body); err != nil {
+		f.fail(w, err)
+		return
+	}
+	if !ValidBUID(body.BUID) {
+		f.fail(w, ErrBadBUID)
+		return
+	}
+	if err := f.manager.Block(state.BUID, body.BUID); err != nil {
+		f.fail(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Unblock removes {buid} from the callers block list
+func (f *Friends) Unblock(w http.ResponseWriter, r *http.Request) {
+	state := GetState(r)
+	buid := chi.URLParam(r, "buid")
+	if !ValidBUID(buid) {
+		f.fail(w, ErrBadBUID)
+		return
+	}
+	if err := f.manager.Unblock(state.BUID, buid); err != nil {
+		f.fail(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
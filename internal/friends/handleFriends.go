@@ -2,8 +2,101 @@ package friends
 
 import (
 	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
 )
 
+// GetFriends returns a page of the callers friend list. The page is controlled
+// with the sort, limit, and cursor query parameters. Setting presence=true
+// includes each friends account and current presence status.
 func (f *Friends) GetFriends(w http.ResponseWriter, r *http.Request) {
-	w.Write([]byte("GetFriends"))
+	state := GetState(r)
+	query := r.URL.Query()
+	sort, err := ParseSort(query.Get("sort"))
+	if err != nil {
+		f.fail(w, err)
+		return
+	}
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	presence, _ := strconv.ParseBool(query.Get("presence"))
+	page, err := f.manager.GetFriends(state.BUID, Page{
+		Sort:     sort,
+		Limit:    limit,
+		Cursor:   query.Get("cursor"),
+		Presence: presence,
+	})
+	if err != nil {
+		f.fail(w, err)
+		return
+	}
+	f.reply(w, http.StatusOK, page)
+}
+
+// GetMutual returns a page of the friends the caller shares with {buid}. The page
+// is controlled with the sort, limit, and cursor query parameters.
+func (f *Friends) GetMutual(w http.ResponseWriter, r *http.Request) {
+	state := GetState(r)
+	buid := chi.URLParam(r, "buid")
+	if !ValidBUID(buid) {
+		f.fail(w, ErrBadBUID)
+		return
+	}
+	query := r.URL.Query()
+	sort, err := ParseSort(query.Get("sort"))
+	if err != nil {
+		f.fail(w, err)
+		return
+	}
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	page, err := f.manager.Mutual(state.BUID, buid, Page{
+		Sort:   sort,
+		Limit:  limit,
+		Cursor: query.Get("cursor"),
+	})
+	if err != nil {
+		f.fail(w, err)
+		return
+	}
+	f.reply(w, http.StatusOK, page)
+}
+
+// GetConnections returns the friends-of-friends of the caller up to the degree
+// query parameter.
+func (f *Friends) GetConnections(w http.ResponseWriter, r *http.Request) {
+	state := GetState(r)
+	query := r.URL.Query()
+	degree, err := strconv.Atoi(query.Get("degree"))
+	if err != nil && query.Get("degree") != "" {
+		f.fail(w, ErrBadDegree)
+		return
+	}
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	out, err := f.manager.Connections(state.BUID, degree, limit)
+	if err != nil {
+		f.fail(w, err)
+		return
+	}
+	f.reply(w, http.StatusOK, out)
+}
+
+// Mute stops presence changes of the friend {buid} from being announced to the
+// caller
+func (f *Friends) Mute(w http.ResponseWriter, r *http.Request) {
+	state := GetState(r)
+	if err := f.manager.Mute(state.BUID, chi.URLParam(r, "buid")); err != nil {
+		f.fail(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Unmute announces presence changes of {buid} to the caller again
+func (f *Friends) Unmute(w http.ResponseWriter, r *http.Request) {
+	state := GetState(r)
+	if err := f.manager.Unmute(state.BUID, chi.URLParam(r, "buid")); err != nil {
+		f.fail(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
 }
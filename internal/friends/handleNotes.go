@@ -0,0 +1,62 @@
+package friends
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// DefaultDeadNotes is how many dead lettered notifications are listed if no
+// limit is given
+const DefaultDeadNotes = 50
+
+// MaxDeadNotes limits how many dead lettered notifications are listed or
+// replayed per request
+const MaxDeadNotes = 1000
+
+// ReplayBody is the inbound json body of a dead letter replay
+type ReplayBody struct {
+	Count int `json:"count"`
+}
+
+// ReplayReply is the reply of a dead letter replay
+type ReplayReply struct {
+	Replayed int `json:"replayed"`
+}
+
+// GetDeadNotes lists dead lettered notifications, newest first, limited with
+// the limit query parameter
+func (f *Friends) GetDeadNotes(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 {
+		limit = DefaultDeadNotes
+	}
+	if limit > MaxDeadNotes {
+		limit = MaxDeadNotes
+	}
+	out, err := f.manager.GetDeadNotes(limit)
+	if err != nil {
+		f.fail(w, err)
+		return
+	}
+	f.reply(w, http.StatusOK, out)
+}
+
+// ReplayDeadNotes queues the oldest dead lettered notifications for delivery
+// again
+func (f *Friends) ReplayDeadNotes(w http.ResponseWriter, r *http.Request) {
+	body := ReplayBody{}
+	if err := decode(r, &body); err != nil {
+		f.fail(w, err)
+		return
+	}
+	if body.Count <= 0 || body.Count > MaxDeadNotes {
+		f.fail(w, ErrBadBody)
+		return
+	}
+	n, err := f.manager.ReplayDeadNotes(body.Count)
+	if err != nil {
+		f.fail(w, err)
+		return
+	}
+	f.reply(w, http.StatusOK, &ReplayReply{Replayed: n})
+}
@@ -0,0 +1,49 @@
+package friends
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi"
+)
+
+// AddRoster records a match roster reported by a game server
+func (f *Friends) AddRoster(w http.ResponseWriter, r *http.Request) {
+	body := Roster{}
+	if err := decode(r, &body); err != nil {
+		f.fail(w, err)
+		return
+	}
+	if err := f.manager.AddRoster(body); err != nil {
+		f.fail(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetPlayed returns the accounts the caller recently played with
+func (f *Friends) GetPlayed(w http.ResponseWriter, r *http.Request) {
+	state := GetState(r)
+	out, err := f.manager.GetPlayed(state.BUID)
+	if err != nil {
+		f.fail(w, err)
+		return
+	}
+	f.reply(w, http.StatusOK, out)
+}
+
+// RequestPlayed sends a friend request from the caller to {buid} from the
+// callers recently played list
+func (f *Friends) RequestPlayed(w http.ResponseWriter, r *http.Request) {
+	state := GetState(r)
+	buid := chi.URLParam(r, "buid")
+	if !ValidBUID(buid) {
+		f.fail(w, ErrBadBUID)
+		return
+	}
+	req, err := f.manager.RequestPlayed(state.BUID, buid)
+	if err != nil {
+		f.fail(w, err)
+		return
+	}
+	f.reply(w, http.StatusCreated, req)
+}
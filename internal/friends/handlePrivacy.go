@@ -0,0 +1,62 @@
+package friends
+
+import (
+	"net/http"
+)
+
+// SearchableBody is the json body listing accounts checked for search, and the
+// reply listing the accounts which may be found
+type SearchableBody struct {
+	BUIDs []string `json:"buids"`
+}
+
+// GetPrivacy returns the callers privacy settings
+func (f *Friends) GetPrivacy(w http.ResponseWriter, r *http.Request) {
+	state := GetState(r)
+	out, err := f.manager.GetPrivacy(state.BUID)
+	if err != nil {
+		f.fail(w, err)
+		return
+	}
+	f.reply(w, http.StatusOK, out)
+}
+
+// SetPrivacy updates the callers privacy settings. Settings missing from the
+// body are left unchanged.
+func (f *Friends) SetPrivacy(w http.ResponseWriter, r *http.Request) {
+	state := GetState(r)
+	cur, err := f.manager.GetPrivacy(state.BUID)
+	if err != nil {
+		f.fail(w, err)
+		return
+	}
+	if err := decode(r, cur); err != nil {
+		f.fail(w, err)
+		return
+	}
+	if err := f.manager.SetPrivacy(state.BUID, cur); err != nil {
+		f.fail(w, err)
+		return
+	}
+	f.reply(w, http.StatusOK, cur)
+}
+
+// GetSearchable returns the accounts of the body which may be found in search.
+// The search service calls this to leave out accounts hidden from search.
+func (f *Friends) GetSearchable(w http.ResponseWriter, r *http.Request) {
+	body := SearchableBody{}
+	if err := decode(r, &body); err != nil {
+		f.fail(w, err)
+		return
+	}
+	if len(body.BUIDs) == 0 || len(body.BUIDs) > MaxMultiStatus {
+		f.fail(w, ErrBadBody)
+		return
+	}
+	out, err := f.manager.Searchable(body.BUIDs...)
+	if err != nil {
+		f.fail(w, err)
+		return
+	}
+	f.reply(w, http.StatusOK, &SearchableBody{BUIDs: out})
+}
@@ -0,0 +1,103 @@
+package friends
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi"
+)
+
+// RequestBody is the inbound json body used to send a friend request
+type RequestBody struct {
+	BUID string `json:"buid"`
+}
+
+// GetRequests returns the callers incoming and outgoing friend requests
+func (f *Friends) GetRequests(w http.ResponseWriter, r *http.Request) {
+	state := GetState(r)
+	data, err := f.manager.GetRequests(state.BUID)
+	if err != nil {
+		f.fail(w, err)
+		return
+	}
+	f.reply(w, http.StatusOK, data)
+}
+
+// SendRequest sends a friend request from the caller to the buid in the body
+func (f *Friends) SendRequest(w http.ResponseWriter, r *http.Request) {
+	state := GetState(r)
+	body := RequestBody{}
+	if err := decode(r, &body); err != nil {
+		f.fail(w, err)
+		return
+	}
+	if !ValidBUID(body.BUID) {
+		f.fail(w, ErrBadBUID)
+		return
+	}
+	req, err := f.manager.SendRequest(state.BUID, body.BUID)
+	if err != nil {
+		f.fail(w, err)
+		return
+	}
+	f.reply(w, http.StatusCreated, req)
+}
+
+// AcceptRequest accepts the friend request sent to the caller by {buid}
+func (f *Friends) AcceptRequest(w http.ResponseWriter, r *http.Request) {
+	state := GetState(r)
+	buid := chi.URLParam(r, "buid")
+	if !ValidBUID(buid) {
+		f.fail(w, ErrBadBUID)
+		return
+	}
+	if err := f.manager.AcceptRequest(state.BUID, buid); err != nil {
+		f.fail(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeclineRequest declines the friend request sent to the caller by {buid}
+func (f *Friends) DeclineRequest(w http.ResponseWriter, r *http.Request) {
+	state := GetState(r)
+	buid := chi.URLParam(r, "buid")
+	if !ValidBUID(buid) {
+		f.fail(w, ErrBadBUID)
+		return
+	}
+	if err := f.manager.DeclineRequest(state.BUID, buid); err != nil {
+		f.fail(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CancelRequest cancels the friend request sent by the caller to {buid}
+func (f *Friends) CancelRequest(w http.ResponseWriter, r *http.Request) {
+	state := GetState(r)
+	buid := chi.URLParam(r, "buid")
+	if !ValidBUID(buid) {
+		f.fail(w, ErrBadBUID)
+		return
+	}
+	if err := f.manager.CancelRequest(state.BUID, buid); err != nil {
+		f.fail(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RemoveFriend removes the friendship between the caller and {buid}
+func (f *Friends) RemoveFriend(w http.ResponseWriter, r *http.Request) {
+	state := GetState(r)
+	buid := chi.URLParam(r, "buid")
+	if !ValidBUID(buid) {
+		f.fail(w, ErrBadBUID)
+		return
+	}
+	if err := f.manager.RemoveFriend(state.BUID, buid); err != nil {
+		f.fail(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,109 @@
+package friends
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/BethesdaNet/friends-go/internal/friends/status"
+	"github.com/go-chi/chi"
+)
+
+// GetStatus returns the callers status for the product/platform of the request
+func (f *Friends) GetStatus(w http.ResponseWriter, r *http.Request) {
+	state := GetState(r)
+	out := &Status{}
+	if err := f.manager.GetSingleStatus(state.BUID, state.Product, state.Platform, state.Language, state.Country, out); err != nil {
+		f.fail(w, err)
+		return
+	}
+	f.reply(w, http.StatusOK, out)
+}
+
+// GetStatusGroup returns every status (global and product/platform) of the caller
+func (f *Friends) GetStatusGroup(w http.ResponseWriter, r *http.Request) {
+	state := GetState(r)
+	group, err := f.manager.GetBUID(state.BUID, state.Product, state.Platform, state.Language, state.Country)
+	if err != nil {
+		f.fail(w, err)
+		return
+	}
+	out := make([]*Status, 0, len(group.Key))
+	for _, k := range group.Key {
+		out = append(out, group.Data[k].(*Status))
+	}
+	f.reply(w, http.StatusOK, out)
+}
+
+// GetBUIDStatus returns the status of {buid} as seen by the caller
+func (f *Friends) GetBUIDStatus(w http.ResponseWriter, r *http.Request) {
+	state := GetState(r)
+	buid := chi.URLParam(r, "buid")
+	if !ValidBUID(buid) {
+		f.fail(w, ErrBadBUID)
+		return
+	}
+	statuses, err := f.manager.ViewStatuses(state.BUID, []string{buid}, state.Product, state.Platform)
+	if err != nil {
+		f.fail(w, err)
+		return
+	}
+	f.reply(w, http.StatusOK, statuses[buid])
+}
+
+// GetMultiStatus returns the statuses of the comma separated buids query param as
+// seen by the caller, in the order requested
+func (f *Friends) GetMultiStatus(w http.ResponseWriter, r *http.Request) {
+	state := GetState(r)
+	buids := strings.Split(r.URL.Query().Get("buids"), status.DeValue)
+	for _, id := range buids {
+		if !ValidBUID(id) {
+			f.fail(w, ErrBadBUID)
+			return
+		}
+	}
+	statuses, err := f.manager.ViewStatuses(state.BUID, buids, state.Product, state.Platform)
+	if err != nil {
+		f.fail(w, err)
+		return
+	}
+	out := make([]*Status, 0, len(statuses))
+	for _, id := range buids {
+		if s, ok := statuses[id]; ok {
+			out = append(out, s)
+			delete(statuses, id)
+		}
+	}
+	f.reply(w, http.StatusOK, out)
+}
+
+// SetStatus updates the callers status for the product/platform of the request.
+// The body is applied on top of the current status and validated by status.Check.
+func (f *Friends) SetStatus(w http.ResponseWriter, r *http.Request) {
+	state := GetState(r)
+	cur := &Status{}
+	if err := f.manager.GetSingleStatus(state.BUID, state.Product, state.Platform, state.Language, state.Country, cur); err != nil {
+		f.fail(w, err)
+		return
+	}
+	if err := decode(r, cur); err != nil {
+		f.fail(w, err)
+		return
+	}
+	cur.BUID = state.BUID
+	out := cur.Set(cur.Enum)
+	if err := f.manager.SetStatus(state.BUID, state.Product, state.Platform, state.Language, state.Country, out); err != nil {
+		f.fail(w, err)
+		return
+	}
+	f.reply(w, http.StatusOK, out)
+}
+
+// DelStatus removes the callers status for the product/platform of the request
+func (f *Friends) DelStatus(w http.ResponseWriter, r *http.Request) {
+	state := GetState(r)
+	if err := f.manager.DelStatus(state.BUID, state.Product, state.Platform); err != nil {
+		f.fail(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
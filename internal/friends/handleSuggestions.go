@@ -0,0 +1,33 @@
+package friends
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi"
+)
+
+// GetSuggestions returns the callers precomputed friend suggestions
+func (f *Friends) GetSuggestions(w http.ResponseWriter, r *http.Request) {
+	state := GetState(r)
+	out, err := f.manager.GetSuggestions(state.BUID)
+	if err != nil {
+		f.fail(w, err)
+		return
+	}
+	f.reply(w, http.StatusOK, out)
+}
+
+// DismissSuggestion stops {buid} from being suggested to the caller
+func (f *Friends) DismissSuggestion(w http.ResponseWriter, r *http.Request) {
+	state := GetState(r)
+	buid := chi.URLParam(r, "buid")
+	if !ValidBUID(buid) {
+		f.fail(w, ErrBadBUID)
+		return
+	}
+	if err := f.manager.DismissSuggestion(state.BUID, buid); err != nil {
+		f.fail(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,65 @@
+package friends
+
+import (
+	"net/http"
+
+	"github.com/BethesdaNet/friends-go/internal/client"
+	"github.com/BethesdaNet/friends-go/internal/provider"
+)
+
+const (
+	// HealthOK reported when every provider breaker is closed
+	HealthOK = "ok"
+
+	// HealthDegraded reported when any provider breaker is open or half-open
+	HealthDegraded = "degraded"
+)
+
+// Health reports the state of the service dependencies
+type Health struct {
+	Status    string                     `json:"status"`
+	Accounts  client.CacheStats          `json:"accounts"`
+	Providers map[string]*ProviderHealth `json:"providers"`
+}
+
+// ProviderHealth reports the breaker state and cache counters of a provider
+type ProviderHealth struct {
+	Breaker provider.BreakerStats `json:"breaker"`
+	Cache   *client.CacheStats    `json:"cache,omitempty"`
+}
+
+// Health returns the breaker state and cache counters of every provider
+func (m *Manager) Health() *Health {
+	h := &Health{
+		Status:    HealthOK,
+		Accounts:  m.account.Stats(),
+		Providers: make(map[string]*ProviderHealth, len(m.breakers)),
+	}
+	for name, b := range m.breakers {
+		ph := &ProviderHealth{Breaker: b.Stats()}
+		if ph.Breaker.State != provider.BreakerClosed {
+			h.Status = HealthDegraded
+		}
+		h.Providers[name] = ph
+	}
+	for name, ph := range h.Providers {
+		var s client.CacheStats
+		var ok bool
+		switch name {
+		case "identity":
+			s, ok = m.identity.Stats()
+		case "presence":
+			s, ok = m.presence.Stats()
+		}
+		if ok {
+			ph.Cache = &s
+		}
+	}
+	return h
+}
+
+// GetHealth returns the service health. The status code is always 200 so that
+// a degraded dependency does not cause load balancers to cycle healthy tasks.
+func (f *Friends) GetHealth(w http.ResponseWriter, r *http.Request) {
+	f.reply(w, http.StatusOK, f.manager.Health())
+}
@@ -11,19 +11,22 @@ func (f *Friends) Routes() http.Handler {
 	r := chi.NewRouter()
 
 	r.Use(
-		//HandleErrors,
+		HandleErrors,
 		middleware.RequestID,
 		middleware.RealIP,
-		//Logger,
+		Logger,
 		//WrapNewRelic(f.nra),
 	)
 
+	r.Get("/health", f.GetHealth)
 	r.Mount("/", publicRouter(f))
 	r.Mount("/public", publicRouter(f))
-	//r.Mount("/private", privateRouter(f))
+	if f.config.Private {
+		r.Mount("/private", privateRouter(f))
+	}
 
-	//r.NotFound(NotFound)
-	//r.MethodNotAllowed(MethodNotAllowed)
+	r.NotFound(NotFound)
+	r.MethodNotAllowed(MethodNotAllowed)
 
 	return r
 }
@@ -32,8 +35,79 @@ func publicRouter(f *Friends) http.Handler {
 	r := chi.NewRouter()
 
 	r.Route("/v3", func(r chi.Router) {
-		r.Route("/friends", func(r chi.Router) {
-			r.Get("/", f.GetFriends)
+
+		// game server routes authenticated with a server key rather than a player
+		r.With(AuthenticateServer(f.verifier, f.config.ServerKey)).Post("/played", f.AddRoster)
+
+		r.Group(func(r chi.Router) {
+			if f.verifier != nil {
+				r.Use(VerifyKey(f.verifier))
+			}
+			r.Use(Authenticate)
+
+			r.Route("/status", func(r chi.Router) {
+				r.Get("/", f.GetStatus)
+				r.Put("/", f.SetStatus)
+				r.Delete("/", f.DelStatus)
+				r.Get("/all", f.GetStatusGroup)
+				r.Get("/multi", f.GetMultiStatus)
+				r.Get("/{buid}", f.GetBUIDStatus)
+			})
+			r.Route("/privacy", func(r chi.Router) {
+				r.Get("/", f.GetPrivacy)
+				r.Put("/", f.SetPrivacy)
+			})
+			r.Route("/friends", func(r chi.Router) {
+				r.Get("/", f.GetFriends)
+				r.Get("/connections", f.GetConnections)
+				r.Delete("/{buid}", f.RemoveFriend)
+				r.Get("/{buid}/mutual", f.GetMutual)
+				r.Put("/{buid}/mute", f.Mute)
+				r.Delete("/{buid}/mute", f.Unmute)
+				r.Route("/requests", func(r chi.Router) {
+					r.Get("/", f.GetRequests)
+					r.Post("/", f.SendRequest)
+					r.Delete("/{buid}", f.CancelRequest)
+					r.Post("/{buid}/accept", f.AcceptRequest)
+					r.Post("/{buid}/decline", f.DeclineRequest)
+				})
+				r.Route("/played", func(r chi.Router) {
+					r.Get("/", f.GetPlayed)
+					r.Post("/{buid}/request", f.RequestPlayed)
+				})
+				r.Route("/suggestions", func(r chi.Router) {
+					r.Get("/", f.GetSuggestions)
+					r.Delete("/{buid}", f.DismissSuggestion)
+				})
+				r.Route("/blocks", func(r chi.Router) {
+					r.Get("/", f.GetBlocks)
+					r.Post("/", f.Block)
+					r.Delete("/{buid}", f.Unblock)
+				})
+			})
+		})
+	})
+
+	return r
+}
+
+// privateRouter serves routes for other platform services. These routes are only
+// mounted on private tasks and every call must carry the service key.
+func privateRouter(f *Friends) http.Handler {
+	r := chi.NewRouter()
+	r.Use(AuthenticateService(f.config.ServiceKey))
+
+	r.Route("/v3", func(r chi.Router) {
+		r.Route("/accounts", func(r chi.Router) {
+			r.Post("/invalidate", f.InvalidateAccounts)
+			r.Post("/merge", f.MergeAccounts)
+			r.Post("/searchable", f.GetSearchable)
+			r.Get("/{buid}/export", f.ExportAccount)
+			r.Post("/{buid}/erase", f.EraseAccount)
+		})
+		r.Route("/notifications/dead", func(r chi.Router) {
+			r.Get("/", f.GetDeadNotes)
+			r.Post("/replay", f.ReplayDeadNotes)
 		})
 	})
 
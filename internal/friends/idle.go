@@ -0,0 +1,149 @@
+package friends
+
+import (
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/BethesdaNet/friends-go/internal/friends/status"
+)
+
+const (
+	// DefaultIdleThreshold is how long an online account may go without activity
+	// before it is moved to idle if the configured threshold is zero
+	DefaultIdleThreshold = time.Minute * 10
+
+	// OnlineShards is how many sets online accounts are spread across. Each shard
+	// is swept by one instance at a time.
+	OnlineShards = 16
+
+	// IdleBatchSize is how many accounts of a shard are read at once
+	IdleBatchSize = 100
+
+	// NoteWentIdle notification title sent to online friends of an account idle
+	// on every status it is present on
+	NoteWentIdle = "Presence Status Idle"
+)
+
+// shardOf returns the online shard of buid
+func shardOf(buid string) int {
+	h := fnv.New32a()
+	h.Write([]byte(buid))
+	return int(h.Sum32() % OnlineShards)
+}
+
+// markOnline adds buid to its online shard to be checked by the idle sweep
+func (m *Manager) markOnline(buid string) error {
+	return m.dba.SAdd(fmt.Sprintf(PaOnline, shardOf(buid)), buid)
+}
+
+// sweepIdle claims every online shard not yet claimed, starting from a random
+// shard, and moves their inactive accounts to idle. It runs on every instance;
+// a claim lasts one daemon interval so each shard is swept by one instance per
+// interval and the shards are spread across instances.
+func (m *Manager) sweepIdle() {
+	start := rand.Intn(OnlineShards)
+	for i := 0; i < OnlineShards; i++ {
+		shard := (start + i) % OnlineShards
+		ok, err := m.dba.SetNX(fmt.Sprintf(PaIdleLock, shard), 1, DefaultDaemonInterval)
+		if err != nil {
+			log.Printf("manager: idle: %v", err)
+			return
+		}
+		if !ok {
+			continue
+		}
+		if err := m.sweepShard(shard); err != nil {
+			log.Printf("manager: idle: shard %d: %v", shard, err)
+		}
+	}
+}
+
+// sweepShard checks every account of the shard in batches and removes the
+// accounts no longer online
+func (m *Manager) sweepShard(shard int) error {
+	key := fmt.Sprintf(PaOnline, shard)
+	ids, err := m.dba.SMembers(key)
+	if err != nil {
+		return err
+	}
+	gone := []string{}
+	for i := 0; i < len(ids); i += IdleBatchSize {
+		end := i + IdleBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		out, err := m.idle(ids[i:end])
+		if err != nil {
+			return err
+		}
+		gone = append(gone, out...)
+	}
+	if len(gone) == 0 {
+		return nil
+	}
+	return m.dba.SRem(key, gone...)
+}
+
+// idle moves the online statuses of buids inactive past the idle threshold to
+// idle. Friends are notified through the presence fan-out. The statuses and
+// last activity of every account are read in three round trips; only accounts
+// moved to idle are written. It returns the accounts without an online status.
+func (m *Manager) idle(buids []string) ([]string, error) {
+	groups, err := m.groups(buids)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, len(buids))
+	for i, id := range buids {
+		keys[i] = fmt.Sprintf(status.KyBUIDLastActivity, id)
+	}
+	rows, err := m.dba.MGet(keys...)
+	if err != nil {
+		return nil, err
+	}
+
+	gone := []string{}
+	for i, id := range buids {
+		group, ok := groups[id]
+		if !ok {
+			continue
+		}
+		var last time.Time
+		if b, err := rowBytes(rows[i]); err == nil {
+			last, _ = time.Parse(time.RFC3339, string(b))
+		}
+		inactive := !last.IsZero() && time.Since(last) > m.threshold()
+
+		online := false
+		for _, k := range group.Key {
+			s := group.Data[k].(*Status)
+			if s.Enum != status.Online {
+				continue
+			}
+			if !inactive {
+				online = true
+				continue
+			}
+			s.Idle = last
+			if err := m.SetStatus(id, s.Product, s.Platform, "", "", s.Set(status.Idle)); err != nil {
+				log.Printf("manager: idle: %s: %v", id, err)
+				online = true
+			}
+		}
+		if !online {
+			gone = append(gone, id)
+		}
+	}
+	return gone, nil
+}
+
+// threshold returns how long an account may be inactive before it is idle
+func (m *Manager) threshold() time.Duration {
+	if m.idleThreshold <= 0 {
+		return DefaultIdleThreshold
+	}
+	return m.idleThreshold
+}
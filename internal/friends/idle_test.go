@@ -0,0 +1,78 @@
+package friends
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/BethesdaNet/friends-go/internal/friends/status"
+)
+
+func TestIdleSweep(t *testing.T) {
+	m, c := newTestManager()
+	m.idleThreshold = time.Minute
+	if err := m.addFriend("a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	m.SetStatus("a", "", "", "", "", (&Status{}).Set(status.Online))
+	m.SetStatus("a", "fallout", "pc", "", "", (&Status{}).Set(status.Online))
+	m.SetStatus("b", "", "", "", "", (&Status{}).Set(status.Online))
+	flushPresence(m, c)
+	popNotes(m, c)
+	last := time.Now().Add(-time.Minute * 2).UTC().Truncate(time.Second)
+	c.Set(fmt.Sprintf(status.KyBUIDLastActivity, "a"), last.Format(time.RFC3339), 0)
+
+	// one sweep covers every shard, and claims are held so a second sweep within
+	// the interval takes none
+	m.sweepIdle()
+	for shard := 0; shard < OnlineShards; shard++ {
+		if _, ok := c.kv[fmt.Sprintf(PaIdleLock, shard)]; !ok {
+			t.Errorf("shard %d: got %v; want %v", shard, ok, true)
+		}
+	}
+	if n := len(c.sets[fmt.Sprintf(PaOnline, shardOf("a"))]); shardOf("a") != shardOf("b") && n != 0 {
+		t.Errorf("got %v; want %v", n, 0)
+	}
+	if !c.sets[fmt.Sprintf(PaOnline, shardOf("b"))]["b"] {
+		t.Errorf("got %v; want %v", false, true)
+	}
+	group, err := m.GetBUID("a", "", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range group.Key {
+		if s := group.Data[k].(*Status); s.Enum != status.Idle || !s.Idle.Equal(last) {
+			t.Errorf("got %v %v; want %v %v", s.Enum, s.Idle, status.Idle, last)
+		}
+	}
+	idle := 0
+	flushPresence(m, c)
+	for _, n := range popNotes(m, c) {
+		if n.Title == NoteWentIdle && n.BUID == "b" {
+			idle++
+		}
+	}
+	if idle != 1 {
+		t.Errorf("got %v; want %v", idle, 1)
+	}
+
+	t.Run("Read", func(t *testing.T) {
+		for _, tc := range []struct {
+			since time.Duration
+			want  status.Kind
+		}{
+			{time.Second, status.Online},
+			{time.Minute * 2, status.Idle},
+		} {
+			ts := time.Now().Add(-tc.since).UTC().Format(time.RFC3339)
+			c.Set(fmt.Sprintf(status.KyBUIDLastActivity, "b"), ts, 0)
+			out := &Status{}
+			if err := m.GetSingleStatus("b", "", "", "", "", out); err != nil {
+				t.Fatal(err)
+			}
+			if out.Enum != tc.want {
+				t.Errorf("%v inactive: got %v; want %v", tc.since, out.Enum, tc.want)
+			}
+		}
+	})
+}
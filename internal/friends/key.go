@@ -0,0 +1,43 @@
+package friends
+
+// Friend graph keys share the {buid} hash-tag convention from status/key.go so
+// every record owned by an account lands in the same redis cluster slot.
+//
+// Ha=Hashes, Ky=Key, Pa=Pattern, Sx=Suffix
+const (
+	KyPresenceDue     = "friends.presence.due"
+	KySuggestQueue    = "friends.suggestions.queue"
+	KySuggestRefresh  = "friends.suggestions.refresh"
+	PaIdleLock        = "friends.idle.lock.%d"
+	PaOnline          = "friends.online.%d"
+	PaAnnounced       = "{%s}" + SxAnnounced
+	PaBlocks          = "{%s}" + SxBlocks
+	PaBlockedBy       = "{%s}" + SxBlockedBy
+	PaDismissed       = "{%s}" + SxDismissed
+	PaFriends         = "{%s}" + SxFriends
+	PaFriendsSince    = "{%s}" + SxFriendsSince
+	PaMuted           = "{%s}" + SxMuted
+	PaPlayed          = "{%s}" + SxPlayed
+	PaPresencePending = "{%s}" + SxPresencePending
+	PaPrivacy         = "{%s}" + SxPrivacy
+	PaProducts        = "{%s}" + SxProducts
+	PaRequest         = "{%s}" + SxRequest + ".%s"
+	PaRequestsIn      = "{%s}" + SxRequestsIn
+	PaRequestsOut     = "{%s}" + SxRequestsOut
+	PaSuggestions     = "{%s}" + SxSuggestions
+	SxAnnounced       = ".presence.announced"
+	SxBlocks          = ".blocks"
+	SxBlockedBy       = ".blocked_by"
+	SxDismissed       = ".suggestions.dismissed"
+	SxFriends         = ".friends"
+	SxFriendsSince    = ".friends.since"
+	SxMuted           = ".friends.muted"
+	SxPlayed          = ".played"
+	SxPresencePending = ".presence.pending"
+	SxPrivacy         = ".privacy"
+	SxProducts        = ".products"
+	SxRequest         = ".request"
+	SxRequestsIn      = ".requests.in"
+	SxRequestsOut     = ".requests.out"
+	SxSuggestions     = ".suggestions"
+)
@@ -0,0 +1,104 @@
+package friends
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/BethesdaNet/friends-go/internal/db/redis"
+	"github.com/BethesdaNet/friends-go/internal/friends/status"
+)
+
+// KeyspaceFlags are the keyspace notification flags the manager relies on;
+// keyspace events (K) for expired keys (x)
+const KeyspaceFlags = "Kx"
+
+// NoteFriendRequestExpired notification title sent to the sender of a request
+// which expired before the recipient answered it
+const NoteFriendRequestExpired = "Friend Request Expired"
+
+// NoteWentOffline notification title sent to online friends of an account no
+// longer online on any status
+const NoteWentOffline = "Presence Status Offline"
+
+// listen subscribes to keyspace notifications of the keys the manager expires
+// and handles them until the manager is closed. One pattern covers every {buid}
+// key since a message is delivered once per matching pattern.
+func (m *Manager) listen() error {
+	if err := m.dba.EnableKeyspace(KeyspaceFlags); err != nil {
+		log.Printf("manager: keyspace: config: %v", err)
+	}
+	return m.dba.Listen(m.done, m.keyspace, status.PxKeySpace+"{*}*")
+}
+
+// keyspace handles a keyspace notification. Every instance is subscribed but
+// only the leader handles expirations, checking it still leads first; keys
+// expiring during a failover are cleaned up when their indexes are next read.
+func (m *Manager) keyspace(msg *redis.Message) {
+	if msg.Payload != status.PsExpired || !m.leads(m.token()) {
+		return
+	}
+	key := strings.TrimPrefix(msg.Channel, status.PxKeySpace)
+	var err error
+	if to, from, ok := parseRequestKey(key); ok {
+		err = m.expireRequest(to, from)
+	} else if buid, ok := parseBUID(key); ok {
+		err = m.expireStatus(buid, key)
+	}
+	if err != nil {
+		log.Printf("manager: keyspace: %s: %v", key, err)
+	}
+}
+
+// expireStatus removes an expired presence status from the buid key index and
+// schedules the presence of the account to be announced to its friends. Keys
+// not found in the index are not presence statuses or were handled by another
+// instance.
+func (m *Manager) expireStatus(buid, key string) error {
+	n, err := m.dba.SRemCount(fmt.Sprintf(status.PaKeys, buid), key)
+	if err != nil || n == 0 {
+		return err
+	}
+	return m.schedulePresence(buid)
+}
+
+// expireRequest removes an expired request from both indexes and notifies the
+// sender. Every instance receives the notification, so only the instance that
+// removes the senders index entry notifies.
+func (m *Manager) expireRequest(to, from string) error {
+	if err := m.dba.SRem(fmt.Sprintf(PaRequestsIn, to), from); err != nil {
+		return err
+	}
+	n, err := m.dba.SRemCount(fmt.Sprintf(PaRequestsOut, from), to)
+	if err != nil || n == 0 {
+		return err
+	}
+	m.SendNotification(NoteFriendRequestExpired, from, &Request{From: from, To: to}, false)
+	return nil
+}
+
+// parseBUID returns the buid hash tag of a {buid} key
+func parseBUID(key string) (string, bool) {
+	end := strings.Index(key, "}")
+	if !strings.HasPrefix(key, "{") || end < 2 {
+		return "", false
+	}
+	return key[1:end], true
+}
+
+// parseRequestKey returns the recipient and sender of a PaRequest key
+func parseRequestKey(key string) (to, from string, ok bool) {
+	if !strings.HasPrefix(key, "{") {
+		return "", "", false
+	}
+	end := strings.Index(key, "}")
+	if end < 0 {
+		return "", "", false
+	}
+	to, rest := key[1:end], key[end+1:]
+	if !strings.HasPrefix(rest, SxRequest+".") {
+		return "", "", false
+	}
+	from = strings.TrimPrefix(rest, SxRequest+".")
+	return to, from, to != "" && from != ""
+}
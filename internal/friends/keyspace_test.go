@@ -0,0 +1,103 @@
+package friends
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/BethesdaNet/friends-go/internal/db/redis"
+	"github.com/BethesdaNet/friends-go/internal/friends/status"
+)
+
+func TestRequestExpiry(t *testing.T) {
+	m, c := newTestManager()
+	if _, err := m.SendRequest("a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	popNotes(m, c)
+
+	t.Run("Parse", func(t *testing.T) {
+		to, from, ok := parseRequestKey(fmt.Sprintf(PaRequest, "b", "a"))
+		if !ok || to != "b" || from != "a" {
+			t.Errorf("got %v,%v,%v; want %v,%v,%v", to, from, ok, "b", "a", true)
+		}
+		for _, bad := range []string{"{b}.friends", "b.request.a", "{b}.request."} {
+			if _, _, ok := parseRequestKey(bad); ok {
+				t.Errorf("got %v; want %v: %s", ok, false, bad)
+			}
+		}
+	})
+	/* ------------------------------------------------------------------------ */
+	t.Run("Expired", func(t *testing.T) {
+		key := fmt.Sprintf(PaRequest, "b", "a")
+		delete(c.kv, key)
+		msg := &redis.Message{Channel: status.PxKeySpace + key, Payload: status.PsExpired}
+		m.keyspace(msg)
+		m.keyspace(msg)
+		if len(c.sets[fmt.Sprintf(PaRequestsIn, "b")]) != 0 || len(c.sets[fmt.Sprintf(PaRequestsOut, "a")]) != 0 {
+			t.Errorf("got %v; want %v", c.sets, "empty request indexes")
+		}
+		notes := popNotes(m, c)
+		if n := len(notes); n != 1 {
+			t.Fatalf("got %v; want %v", n, 1)
+		}
+		if n := notes[0]; n.Title != NoteFriendRequestExpired || n.BUID != "a" {
+			t.Errorf("got %+v; want %v", n, NoteFriendRequestExpired)
+		}
+	})
+	/* ------------------------------------------------------------------------ */
+	t.Run("Missed", func(t *testing.T) {
+		if _, err := m.SendRequest("a", "c"); err != nil {
+			t.Fatal(err)
+		}
+		popNotes(m, c)
+		delete(c.kv, fmt.Sprintf(PaRequest, "c", "a"))
+		data, err := m.GetRequests("c")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data.Incoming) != 0 || len(c.sets[fmt.Sprintf(PaRequestsOut, "a")]) != 0 {
+			t.Errorf("got %+v; want %v", data, "no requests")
+		}
+	})
+}
+
+func TestStatusExpiry(t *testing.T) {
+	m, c := newTestManager()
+	for _, id := range []string{"b", "c"} {
+		if err := m.addFriend("a", id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.SetStatus("a", "", "", "", "", (&Status{}).Set(status.Online))
+	m.SetStatus("b", "fallout", "pc", "", "", (&Status{}).Set(status.Online))
+	flushPresence(m, c)
+	popNotes(m, c)
+
+	key := status.Key("a", "", "", false)
+	delete(c.kv, key)
+	msg := &redis.Message{Channel: status.PxKeySpace + key, Payload: status.PsExpired}
+	m.keyspace(msg)
+	m.keyspace(msg)
+	flushPresence(m, c)
+
+	// b is only online on a product status and is notified once
+	if notes := popNotes(m, c); len(notes) != 1 || notes[0].BUID != "b" || notes[0].Title != NoteWentOffline {
+		t.Errorf("got %v; want %v to %v", notes, NoteWentOffline, "b")
+	}
+
+	key = status.Key("b", "fallout", "pc", false)
+	m.SetStatus("a", "", "", "", "", (&Status{}).Set(status.Online))
+	flushPresence(m, c)
+	popNotes(m, c)
+	delete(c.kv, key)
+	m.keyspace(&redis.Message{Channel: status.PxKeySpace + key, Payload: status.PsExpired})
+	flushPresence(m, c)
+	notes := popNotes(m, c)
+	if n := len(notes); n != 1 {
+		t.Fatalf("got %v; want %v", n, 1)
+	}
+	if n := notes[0]; n.Title != NoteWentOffline || n.BUID != "a" || !strings.Contains(string(n.Data), `"buid":"b"`) {
+		t.Errorf("got %+v; want %v", n, NoteWentOffline)
+	}
+}
@@ -0,0 +1,88 @@
+package friends
+
+import (
+	"log"
+	"time"
+)
+
+const (
+	// KyLeader is the lease key held by the instance running the singleton daemon
+	// jobs
+	KyLeader = "friends.leader"
+
+	// DefaultLeaseTTL is how long the leader lease lasts without renewal. Leases
+	// are renewed every daemon tick, so another instance takes over within this
+	// long once the leader dies.
+	DefaultLeaseTTL = DefaultDaemonInterval * 3
+)
+
+// elect acquires or renews the leader lease returning true if this instance is
+// the leader. Failures are treated as losing the lease so that two instances
+// never both believe they lead.
+func (m *Manager) elect() bool {
+	if m.lease == nil {
+		return true
+	}
+	ok, err := m.lease.Acquire()
+	if err != nil {
+		log.Printf("manager: leader: %v", err)
+		ok = false
+	}
+	if ok != m.leading {
+		if ok {
+			log.Printf("manager: leader: acquired lease (token %d)", m.lease.Token())
+		} else {
+			log.Printf("manager: leader: lost lease")
+		}
+		m.leading = ok
+	}
+	return ok
+}
+
+// token returns the token of the leader lease held by this instance, or zero if
+// it is not held
+func (m *Manager) token() int64 {
+	if m.lease == nil {
+		return 0
+	}
+	return m.lease.Token()
+}
+
+// leads returns true while token is the token of the lease held in the db.
+// Daemon jobs check it between steps and stop once it fails, so a leader which
+// was replaced stops soon after. It is not a fence: the check and the writes
+// following it are separate commands, so a leader pausing between them may
+// still write once after losing the lease. Leader jobs are safe to run twice.
+func (m *Manager) leads(token int64) bool {
+	if m.lease == nil {
+		return true
+	}
+	if token == 0 || !m.lease.Held() {
+		return false
+	}
+	cur, err := m.lease.Current()
+	if err != nil {
+		log.Printf("manager: leader: %v", err)
+		return false
+	}
+	return cur == token
+}
+
+// resign releases the leader lease so another instance takes over without
+// waiting for the lease to expire
+func (m *Manager) resign() {
+	if m.lease == nil {
+		return
+	}
+	if err := m.lease.Release(); err != nil {
+		log.Printf("manager: leader: release: %v", err)
+	}
+}
+
+// leaseTTL returns the leader lease ttl used when creating the lease
+func leaseTTL(d time.Duration) time.Duration {
+	if d <= 0 {
+		return DefaultLeaseTTL
+	}
+	return d
+}
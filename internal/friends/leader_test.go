@@ -0,0 +1,77 @@
+package friends
+
+import (
+	"testing"
+	"time"
+
+	"github.com/BethesdaNet/friends-go/internal/db/redis"
+)
+
+func TestLeader(t *testing.T) {
+	m, c := newTestManager()
+	a := redis.NewLease(m.dba, KyLeader, time.Minute)
+	b := redis.NewLease(m.dba, KyLeader, time.Minute)
+
+	if ok, err := a.Acquire(); err != nil || !ok {
+		t.Fatalf("got %v,%v; want %v", ok, err, true)
+	}
+	if ok, _ := b.Acquire(); ok {
+		t.Errorf("got %v; want %v", ok, false)
+	}
+	if ok, _ := a.Acquire(); !ok || !a.Held() {
+		t.Errorf("got %v; want %v", ok, true)
+	}
+	first := a.Token()
+
+	// the lease expires and is taken over; the previous holder can not renew
+	delete(c.kv, "{"+KyLeader+"}")
+	if ok, _ := b.Acquire(); !ok || b.Token() <= first {
+		t.Errorf("got %v,%v; want %v,>%v", ok, b.Token(), true, first)
+	}
+	if ok, _ := a.Acquire(); ok || a.Token() != 0 {
+		t.Errorf("got %v; want %v", ok, false)
+	}
+	if cur, _ := b.Current(); cur != b.Token() {
+		t.Errorf("got %v; want %v", cur, b.Token())
+	}
+
+	// a stale release does not remove the new holders lease
+	if err := a.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := a.Acquire(); !ok {
+		t.Errorf("got %v; want %v", ok, true)
+	}
+	a.Release()
+
+	t.Run("Stale", func(t *testing.T) {
+		stale := redis.NewLease(m.dba, KyLeader, time.Minute)
+		next := redis.NewLease(m.dba, KyLeader, time.Minute)
+		m.lease = stale
+		if !m.elect() {
+			t.Fatalf("got %v; want %v", false, true)
+		}
+		token := m.token()
+
+		// the lease expires while the leader is paused and another instance takes
+		// it; the paused leader still believes it holds the lease
+		delete(c.kv, "{"+KyLeader+"}")
+		if ok, _ := next.Acquire(); !ok || !stale.Held() {
+			t.Fatalf("got %v,%v; want %v,%v", ok, stale.Held(), true, true)
+		}
+		m.queueSuggestions("a")
+		m.precompute(token)
+		if !c.sets[KySuggestQueue]["a"] {
+			t.Errorf("got %v; want %v", false, true)
+		}
+
+		m.lease = next
+		m.precompute(m.token())
+		if c.sets[KySuggestQueue]["a"] {
+			t.Errorf("got %v; want %v", true, false)
+		}
+	})
+}
@@ -3,12 +3,15 @@ package friends
 import (
 	"bytes"
 	"encoding/gob"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"sync"
+	"log"
+	"sort"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/BethesdaNet/friends-go/internal/client"
 	"github.com/BethesdaNet/friends-go/internal/db/redis"
 	"github.com/BethesdaNet/friends-go/internal/friends/status"
 	"github.com/BethesdaNet/friends-go/internal/provider"
@@ -46,14 +49,44 @@ type (
 		note     *provider.Note
 		storage  *provider.Storage
 
-		// account map contains accounts retrieved from identity service
-		account sync.Map
+		// breakers wrap each providers client by provider name, failing fast while
+		// the provider is degraded rather than waiting on request timeouts
+		breakers map[string]*provider.Breaker
+		breaker  provider.BreakerConfig
 
-		// notechan specific for provider.Note outbound msgs
-		notes chan Notification
+		// account cache contains accounts retrieved from identity service. Entries
+		// expire after the configured ttl and are evicted once the cache is full.
+		account *client.LRU
+
+		// lookups coalesces concurrent identity lookups of the same buid
+		lookups flight
+
+		// idleThreshold is how long an online account may be inactive before the
+		// idle sweep moves it to idle
+		idleThreshold time.Duration
+
+		// requestTTL is how long a friend request stays pending
+		requestTTL time.Duration
+
+		// playedWindow is how long accounts are kept in recently played lists
+		playedWindow time.Duration
+
+		// noteWindow is how long notifications are held to be batched per recipient
+		noteWindow time.Duration
+
+		// queue delivers notifications through provider.Note from redis
+		queue queue
 
 		// done chan closes the managers daemon which controls channel operations
 		done chan struct{}
+
+		// ticking is set while the daemon jobs of a tick are running
+		ticking int32
+
+		// lease elects the instance running singleton daemon jobs; leading is
+		// only accessed by the running tick
+		lease   *redis.Lease
+		leading bool
 	}
 
 	// Group used by manager to query a buid for all possible statuses using scan
@@ -74,10 +107,24 @@ type (
 	// Account shorthand for identity.Account
 	Account = identity.Account
 
+	// Status shorthand for status.Status
+	Status = status.Status
+
 	// Notification shorthand for provider.Notification
 	Notification = provider.Notification
 )
 
+const (
+	// DefaultAccountCacheSize used if the account cache size is zero
+	DefaultAccountCacheSize = 50000
+
+	// DefaultAccountCacheTTL used if the account cache ttl is zero
+	DefaultAccountCacheTTL = time.Minute * 10
+
+	// PaAccount is the cache path accounts are stored under
+	PaAccount = "account"
+)
+
 // DefaultDaemonInterval is the minimum rate at which the background daemon sweep
 const DefaultDaemonInterval = time.Second * 10
 
@@ -92,82 +139,81 @@ func (m *Manager) run() {
 		select {
 		case <-m.done:
 			return
-		case n, ok := <-m.notes:
-			if ok {
-				m.deliver(n)
-			}
 		case <-hz.C:
-			/* no-op */
+			m.tick()
 		}
 	}
 }
 
-// SendNotification sends note msg through manager notechan if enabled
-//func (m *Manager) SendNotification(title, buid string, data interface{}, annouce bool) {
-//	if !EnableDaemon {
-//		return
-//	}
-//	m.notes <- Notification{
-//		Title:   title,
-//		BUID:    buid,
-//		Data:    data,
-//		Annouce: annouce,
-//	}
-//}
-
-// deliver processes inbound notifications from notes channel
-func (m *Manager) deliver(n Notification) {
-	b, _ := json.Marshal(n.Data)
-	if n.Announce {
-		m.note.Announcement(n.Title, string(b), n.BUID, nil)
-	} else {
-		m.note.Notification(n.Title, string(b), n.BUID, nil)
+// tick runs the periodic daemon jobs. The idle sweep and presence fan-out are
+// claimed per shard and per account and run on every instance, the other jobs
+// only on the leader. Jobs run outside of the daemon routine so notifications
+// are not held up; a tick is skipped while the last is running.
+func (m *Manager) tick() {
+	if !atomic.CompareAndSwapInt32(&m.ticking, 0, 1) {
+		return
 	}
+	go func() {
+		defer atomic.StoreInt32(&m.ticking, 0)
+		m.sweepIdle()
+		m.fanout()
+		if !m.elect() {
+			return
+		}
+		token := m.token()
+		m.recoverNotes(token)
+		m.precompute(token)
+	}()
 }
 
 /* -------------------------------------------------------------------------- */
 
-// GetBUID retrieves status by key. If key not found set offline status
-// in the DB and return to handler.
-//func (m *Manager) GetBUID(buid, product, platform, language, country string) (*Group, error) {
-//	group, err := m.scan(buid)
-//	if err == nil {
-//		if err = m.fill(group); err != nil {
-//			return nil, err
-//		}
-//		return group, nil
-//	}
-//	switch err {
-//	case ErrScanZero:
-//		log.Printf("group: scan err: %s", err)
-//	default:
-//		return nil, err
-//	}
-//	return group, nil
-//}
+// GetBUID retrieves every status (global and product/platform) stored for buid.
+// If no status is stored an empty group is returned with ErrScanZero logged.
+func (m *Manager) GetBUID(buid, product, platform, language, country string) (*Group, error) {
+	group, err := m.scan(buid)
+	if err == nil {
+		if err = m.fill(group); err != nil {
+			return nil, err
+		}
+		return group, nil
+	}
+	switch err {
+	case ErrScanZero:
+		log.Printf("group: scan err: %s", err)
+	default:
+		return nil, err
+	}
+	return group, nil
+}
 
 // ErrScanZero returned if no results returned on scan of key
 var ErrScanZero = errors.New("no records found")
 
-//func (m *Manager) scan(value string) (*Group, error) {
-//	wild := fmt.Sprintf(status.PaWildBUID, value)
-//	keys, err := m.dba.Scan(wild, MaxScanCount)
-//	if err != nil {
-//		return nil, err
-//	}
-//	if len(keys) == 0 {
-//		return nil, ErrScanZero
-//	}
-//	group := &Group{
-//		Key:   keys,
-//		Query: wild,
-//		Data:  make(map[string]interface{}, len(keys)),
-//	}
-//	for _, k := range keys {
-//		group.Data[k] = &Status{}
-//	}
-//	return group, nil
-//}
+// scan returns a group of the status keys stored for value. Keys are read from
+// the {buid}.keys index maintained by SetStatus/DelStatus rather than a keyspace
+// SCAN, which only walks a single cluster node and would also match the friend
+// graph records sharing the {buid} hash tag.
+func (m *Manager) scan(value string) (*Group, error) {
+	index := fmt.Sprintf(status.PaKeys, value)
+	keys, err := m.dba.SMembers(index)
+	if err != nil {
+		return nil, err
+	}
+	group := &Group{
+		Key:   keys,
+		Query: index,
+		Data:  make(map[string]interface{}, len(keys)),
+	}
+	if len(keys) == 0 {
+		return group, ErrScanZero
+	}
+	sort.Strings(group.Key)
+	for _, k := range keys {
+		group.Data[k] = &Status{BUID: value}
+	}
+	return group, nil
+}
 
 var (
 	// ErrGroupNil returned if provided group ptr is nil
@@ -177,159 +223,278 @@ var (
 	ErrBadEncoding = errors.New("unsupported data encoding retrieved")
 )
 
+// fill decodes the statuses of the group keys. Keys no longer stored (expired)
+// are set offline and pruned from the buid key index.
 func (m *Manager) fill(group *Group) error {
 	if group == nil {
 		return ErrGroupNil
 	}
-	if len(group.Key) > 1 {
-		rows, err := m.dba.MGet(group.Key...)
-		if err != nil {
+	if len(group.Key) == 0 {
+		return nil
+	}
+	rows, err := m.dba.MGet(group.Key...)
+	if err != nil {
+		return err
+	}
+	stale := []string{}
+	for i, row := range rows {
+		in := group.Data[group.Key[i]].(*Status)
+		b, err := rowBytes(row)
+		switch err {
+		case nil:
+		case redis.ErrBadKey:
+			stale = append(stale, group.Key[i])
+			*in = *in.Set(status.Offline)
+			continue
+		default:
 			return err
 		}
-		for i, row := range rows {
-			switch v := row.(type) {
-			case []byte:
-				if err := gob.NewDecoder(bytes.NewBuffer(v)).Decode(group.Data[group.Key[i]]); err != nil {
-					continue
-				}
-			default:
-				return ErrBadEncoding
-			}
+		if err := gob.NewDecoder(bytes.NewBuffer(b)).Decode(in); err != nil {
+			*in = *in.Set(status.Offline)
 		}
-	} else if len(group.Data) == 1 {
-		key := group.Key[0]
-		raw, err := m.dba.GetBytes(key)
-		if err != nil {
-			switch err {
-			case redis.ErrBadKey:
-				/* no-op */
-			default:
-				return err
-			}
-		} else {
-			if err := gob.NewDecoder(bytes.NewBuffer(raw)).Decode(group.Data[key]); err != nil {
-				return err
+	}
+	if len(stale) > 0 {
+		return m.dba.SRem(group.Query, stale...)
+	}
+	return nil
+}
+
+// groups reads every status of each of buids like GetBUID with one round trip
+// for the key indexes and one for the statuses. Keys no longer stored are set
+// offline but not pruned, so reads of many accounts never write.
+func (m *Manager) groups(buids []string) (map[string]*Group, error) {
+	out := make(map[string]*Group, len(buids))
+	ids, indexes := make([]string, 0, len(buids)), make([]string, 0, len(buids))
+	for _, id := range buids {
+		if _, ok := out[id]; ok || id == "" {
+			continue
+		}
+		out[id] = &Group{Query: fmt.Sprintf(status.PaKeys, id)}
+		ids = append(ids, id)
+		indexes = append(indexes, out[id].Query)
+	}
+	if len(indexes) == 0 {
+		return out, nil
+	}
+	sets, err := m.dba.SMembersAll(indexes...)
+	if err != nil {
+		return nil, err
+	}
+	keys := []string{}
+	for i, id := range ids {
+		group := out[id]
+		group.Key = sets[i]
+		sort.Strings(group.Key)
+		group.Data = make(map[string]interface{}, len(group.Key))
+		for _, k := range group.Key {
+			group.Data[k] = &Status{BUID: id}
+		}
+		keys = append(keys, group.Key...)
+	}
+	if len(keys) == 0 {
+		return out, nil
+	}
+	rows, err := m.dba.MGet(keys...)
+	if err != nil {
+		return nil, err
+	}
+	n := 0
+	for _, id := range ids {
+		for _, k := range out[id].Key {
+			in := out[id].Data[k].(*Status)
+			b, err := rowBytes(rows[n])
+			if err != nil || gob.NewDecoder(bytes.NewBuffer(b)).Decode(in) != nil {
+				*in = *in.Set(status.Offline)
 			}
+			n++
 		}
 	}
-	return nil
+	return out, nil
+}
+
+// rowBytes returns the raw bytes of a row returned by MGet. Missing keys return
+// redis.ErrBadKey.
+func rowBytes(row interface{}) ([]byte, error) {
+	switch v := row.(type) {
+	case nil:
+		return nil, redis.ErrBadKey
+	case string:
+		return []byte(v), nil
+	case []byte:
+		return v, nil
+	default:
+		return nil, ErrBadEncoding
+	}
 }
 
 /* -------------------------------------------------------------------------- */
 
-// GetSingleStatus retrieves status by key. If key not found set offline status
-// in the DB and return to handler.
-//func (m *Manager) GetSingleStatus(buid, product, platform, language, country string, in *Status) error {
-//	key := status.Key(buid, product, platform, false)
-//	raw, err := m.dba.GetBytes(key)
-//	if err != nil {
-//		switch err {
-//		case redis.ErrBadKey:
-//			return m.SetStatus(buid, product, platform, language, country, in.Set(status.Offline))
-//		default:
-//			return err
-//		}
-//	}
-//	if err := gob.NewDecoder(bytes.NewBuffer(raw)).Decode(&in); err != nil {
-//		// set offline status to prevent an invalid response to the client.
-//		in.Set(status.Offline)
-//
-//		// Enable flag to save the offline status to redis. Decoding errors could be
-//		// a result of bad data or other unforseen issues.
-//		if EnableSetOfflineOnDecodeErr {
-//			return m.SetStatus(buid, product, platform, language, country, in)
-//		}
-//	}
-//
-//	// if EnableLegacyIdleTimestampFlow is true query redis for idle timestamp for
-//	// the requested key... in the future we should think of another way to set the
-//	// idle timestamp instead of an additional key in redis
-//	if EnableLegacyIdleTimestampFlow && in.Enum > status.AppearOffline {
-//		if ts, ok := m.CheckIdle(buid); ok {
-//			in.Idle = ts
-//			return m.SetStatus(buid, product, platform, language, country, in.Set(status.Idle))
-//		}
-//	}
-//	return nil
-//}
+// GetSingleStatus retrieves status by key. If key not found the status is
+// returned offline.
+func (m *Manager) GetSingleStatus(buid, product, platform, language, country string, in *Status) error {
+	in.BUID, in.Product, in.Platform = buid, product, platform
+	raw, err := m.dba.GetBytes(status.Key(buid, product, platform, false))
+	if err != nil {
+		switch err {
+		case redis.ErrBadKey:
+			*in = *in.Set(status.Offline)
+			return nil
+		default:
+			return err
+		}
+	}
+	if err := gob.NewDecoder(bytes.NewBuffer(raw)).Decode(in); err != nil {
+		// set offline status to prevent an invalid response to the client.
+		*in = *in.Set(status.Offline)
+
+		// Enable flag to save the offline status to redis. Decoding errors could be
+		// a result of bad data or other unforseen issues.
+		if EnableSetOfflineOnDecodeErr {
+			return m.SetStatus(buid, product, platform, language, country, in)
+		}
+	}
+
+	// if EnableLegacyIdleTimestampFlow is true query redis for idle timestamp for
+	// the requested key... in the future we should think of another way to set the
+	// idle timestamp instead of an additional key in redis. Like the idle sweep
+	// only online statuses inactive for longer than the threshold become idle.
+	if EnableLegacyIdleTimestampFlow && in.Enum == status.Online {
+		if ts, ok := m.CheckIdle(buid); ok && time.Since(ts) > m.threshold() {
+			in.Idle = ts
+			return m.SetStatus(buid, product, platform, language, country, in.Set(status.Idle))
+		}
+	}
+	return nil
+}
 
 /* -------------------------------------------------------------------------- */
 
-// GetMultiStatus retrieves presence statuses from db
-//func (m *Manager) GetMultiStatus(buid, product, platform, language, country string) (map[string]*Status, error) {
-//	buids := strings.Split(buid, ",")
-//	keys, delta := []string{}, []string{}
-//	statuses := map[string]*Status{}
-//
-//	for _, id := range buids {
-//		keys = append(keys)
-//		statuses[buid] = &Status{BUID: id}
-//	}
-//
-//	rows, err := m.dba.MGet(keys...)
-//	if err != nil {
-//		return statuses, err
-//	}
-//
-//	// loop over rows and parse each row
-//	for i, row := range rows {
-//		b, ok := row.([]byte)
-//		if !ok {
-//			delta = append(delta, buids[i])
-//		}
-//		if err := gob.NewDecoder(bytes.NewBuffer(b)).Decode(statuses[buid]); err != nil {
-//			continue
-//		}
-//		if len(delta) > 0 {
-//			for _, id := range delta {
-//				m.SetStatus(id, product, platform, language, country, statuses[id].Set(status.Offline))
-//			}
-//		}
-//	}
-//	return statuses, nil
-//}
+// GetMultiStatus retrieves the statuses of the comma separated buids in one
+// MGet. Statuses not stored are returned offline.
+func (m *Manager) GetMultiStatus(buid, product, platform, language, country string) (map[string]*Status, error) {
+	buids := strings.Split(buid, status.DeValue)
+	if len(buids) > MaxMultiStatus {
+		return nil, ErrMaxMultiStatus
+	}
+	return m.statuses(buids, product, platform)
+}
+
+// statuses retrieves the statuses of buids in one MGet regardless of count
+func (m *Manager) statuses(buids []string, product, platform string) (map[string]*Status, error) {
+	keys, order := make([]string, 0, len(buids)), make([]string, 0, len(buids))
+	statuses := make(map[string]*Status, len(buids))
+	for _, id := range buids {
+		if _, ok := statuses[id]; ok || id == "" {
+			continue
+		}
+		keys = append(keys, status.Key(id, product, platform, false))
+		order = append(order, id)
+		statuses[id] = &Status{BUID: id, Product: product, Platform: platform}
+	}
+	if len(keys) == 0 {
+		return statuses, nil
+	}
+
+	rows, err := m.dba.MGet(keys...)
+	if err != nil {
+		return statuses, err
+	}
+
+	// loop over rows and parse each row; rows are returned in key order
+	for i, row := range rows {
+		in := statuses[order[i]]
+		b, err := rowBytes(row)
+		if err != nil || gob.NewDecoder(bytes.NewBuffer(b)).Decode(in) != nil {
+			*in = *in.Set(status.Offline)
+		}
+	}
+	return statuses, nil
+}
+
+// MaxMultiStatus limits how many statuses can be requested at once
+const MaxMultiStatus = 500
+
+// ErrMaxMultiStatus returned when too many statuses are requested at once
+var ErrMaxMultiStatus = errors.New("too many statuses requested")
 
 /* -------------------------------------------------------------------------- */
 
-// SetStatus stores presence status in redis db
-//func (m *Manager) SetStatus(buid, product, platform, language, country string, in *Status) error {
-//	if in.Time.IsZero() {
-//		in.Time = time.Now()
-//	}
-//	buf := &bytes.Buffer{}
-//	if err := gob.NewEncoder(buf).Encode(&in); err != nil {
-//		return err
-//	}
-//
-//	key := status.Key(buid, product, platform, false)
-//	if err := m.dba.SetBytes(key, buf.Bytes()); err != nil {
-//		return err
-//	}
-//
-//	// send notification through notechan to be processed
-//	m.SendNotification("Presence Status Update", buid, in, false)
-//
-//	return nil
-//}
+// SetStatus stores presence status in redis db. The status expires after the
+// status Expire duration if set, otherwise after the db agents configured ttl.
+func (m *Manager) SetStatus(buid, product, platform, language, country string, in *Status) error {
+	in.BUID, in.Product, in.Platform = buid, product, platform
+	if in.Time.IsZero() {
+		in.Time = time.Now()
+	}
+	buf := &bytes.Buffer{}
+	if err := gob.NewEncoder(buf).Encode(in); err != nil {
+		return err
+	}
+
+	key := status.Key(buid, product, platform, false)
+	if in.Expire > 0 {
+		if err := m.dba.SetBytesExpire(key, buf.Bytes(), in.Expire); err != nil {
+			return err
+		}
+	} else if err := m.dba.SetBytes(key, buf.Bytes()); err != nil {
+		return err
+	}
+	if err := m.dba.SAdd(fmt.Sprintf(status.PaKeys, buid), key); err != nil {
+		return err
+	}
+
+	// online accounts are checked for inactivity by the idle sweep
+	if in.Enum == status.Online {
+		if err := m.markOnline(buid); err != nil {
+			return err
+		}
+	}
+
+	// products played are kept to score friend suggestions
+	if product != "" {
+		if err := m.dba.SAdd(fmt.Sprintf(PaProducts, buid), product); err != nil {
+			return err
+		}
+	}
+
+	// friends are notified once the presence window has passed
+	if err := m.schedulePresence(buid); err != nil {
+		return err
+	}
+
+	// send notification through notechan to be processed
+	m.SendNotification(NotePresenceUpdate, buid, in, false)
+
+	return nil
+}
+
+// NotePresenceUpdate notification title sent when a presence status changes
+const NotePresenceUpdate = "Presence Status Update"
 
 /* -------------------------------------------------------------------------- */
 
 // DelStatus removes presence status from db
-//func (m *Manager) DelStatus(buid, product, platform string) error {
-//	key := status.Key(buid, product, platform, false)
-//	if EnableLegacyIdleTimestampFlow {
-//		return m.dba.Del([]string{key, key + status.SxLastActivity}...)
-//	}
-//
-//	if err := m.dba.Del(key); err != nil {
-//		return err
-//	}
-//
-//	// send notification through notechan to be processed
-//	m.SendNotification("Presence Status Update", buid, nil, false)
-//
-//	return nil
-//}
+func (m *Manager) DelStatus(buid, product, platform string) error {
+	key := status.Key(buid, product, platform, false)
+	keys := []string{key}
+	if EnableLegacyIdleTimestampFlow {
+		keys = append(keys, key+status.SxLastActivity)
+	}
+	if err := m.dba.Del(keys...); err != nil {
+		return err
+	}
+	if err := m.dba.SRem(fmt.Sprintf(status.PaKeys, buid), key); err != nil {
+		return err
+	}
+	if err := m.schedulePresence(buid); err != nil {
+		return err
+	}
+
+	// send notification through notechan to be processed
+	m.SendNotification(NotePresenceUpdate, buid, nil, false)
+
+	return nil
+}
 
 /* -------------------------------------------------------------------------- */
 
@@ -345,41 +510,116 @@ func (m *Manager) CheckIdle(buid string) (time.Time, bool) {
 
 /* -------------------------------------------------------------------------- */
 
-// GetAccounts returns map of accounts from identity response
+// GetAccounts returns map of accounts from identity response. Cached accounts
+// are returned without a lookup, ids already being looked up by another caller
+// are waited on, and the rest are looked up from identity in batches. Accounts
+// not found are missing from the map.
 func (m *Manager) GetAccounts(ids ...string) (map[string]*Account, error) {
 	data := make(map[string]*Account, len(ids))
+	miss := make([]string, 0, len(ids))
 	for _, id := range ids {
-		a := &Account{ID: id}
-		if err := m.GetAccount(id, a); err != nil {
-			return data, err
+		if a := (&Account{ID: id}); m.load(a) {
+			data[id] = a
+			continue
+		}
+		miss = append(miss, id)
+	}
+	if len(miss) == 0 {
+		return data, nil
+	}
+	if m.identity == nil {
+		return data, ErrProviderNil
+	}
+
+	own, wait := m.lookups.join(miss)
+	var fail error
+	if len(own) > 0 {
+		// owned ids are resolved even if the lookup panics, otherwise every later
+		// lookup of them would wait on the call for good
+		done := make(map[string]bool, len(own))
+		defer func() {
+			for _, id := range own {
+				if !done[id] {
+					m.lookups.finish(id, nil, ErrLookupAborted)
+				}
+			}
+		}()
+
+		found := []*Account{}
+		fail = m.identity.GetAccounts(own, &found)
+		byID := make(map[string]*Account, len(found))
+		for _, a := range found {
+			byID[a.ID] = a
+		}
+		for _, id := range own {
+			a, ok := byID[id]
+			if !ok {
+				err := fail
+				if err == nil {
+					err = ErrAccountNotFound
+				}
+				done[id] = true
+				m.lookups.finish(id, nil, err)
+				continue
+			}
+			m.store(a)
+			data[id] = a
+			done[id] = true
+			m.lookups.finish(id, a, nil)
 		}
-		data[id] = a
 	}
-	return data, nil
+	for id, c := range wait {
+		<-c.done
+		if c.err != nil {
+			if c.err != ErrAccountNotFound && fail == nil {
+				fail = c.err
+			}
+			continue
+		}
+		data[id] = c.val.(*Account)
+	}
+	return data, fail
 }
 
-// ErrAccountNotFound err returned when account not found by identity service
-var ErrAccountNotFound = errors.New("account not found")
+// Invalidate removes cached accounts so the next lookup reaches identity. Call
+// it when identity reports an account was merged or its username changed.
+func (m *Manager) Invalidate(ids ...string) {
+	for _, id := range ids {
+		m.delete(&Account{ID: id})
+	}
+}
+
+var (
+	// ErrAccountNotFound err returned when account not found by identity service
+	ErrAccountNotFound = errors.New("account not found")
+
+	// ErrLookupAborted returned to callers waiting on an account lookup which
+	// panicked before it resolved
+	ErrLookupAborted = errors.New("account lookup aborted")
+)
 
 // GetAccount func returns account by buid
 func (m *Manager) GetAccount(id string, in *Account) error {
-	if ok := m.load(in); ok {
-		return nil
-	}
-	if err := m.identity.GetAccount(id, in); err != nil {
+	data, err := m.GetAccounts(id)
+	a, ok := data[id]
+	if !ok {
+		if err != nil {
+			return err
+		}
 		return ErrAccountNotFound
 	}
-	return m.store(in)
+	*in = *a
+	return nil
 }
 
 func (m *Manager) load(in interface{}) bool {
 	switch v := in.(type) {
 	case *Account:
-		a, ok := m.account.Load(v.ID)
-		if !ok {
+		kv := m.account.Get(PaAccount, v.ID)
+		if kv == nil {
 			return false
 		}
-		in = *(a.(*Account))
+		*v = *(kv.Value().(*Account))
 		return true
 	default:
 		return false
@@ -394,7 +634,14 @@ var ErrBadDataType = errors.New("bad data type")
 func (m *Manager) store(in interface{}) error {
 	switch v := in.(type) {
 	case *Account:
-		m.account.Store(v.ID, v)
+		// merged accounts are never cached so that lookups always reach identity.
+		// Their graph is moved by the merge identity sends to MergeAccounts, the
+		// account itself does not name the surviving account.
+		if v.State == identity.StateMerged {
+			return m.delete(v)
+		}
+		a := *v
+		m.account.Put(PaAccount, client.NewItem(a.ID, &a))
 		return nil
 	default:
 		return ErrBadDataType
@@ -404,7 +651,10 @@ func (m *Manager) store(in interface{}) error {
 func (m *Manager) delete(in interface{}) error {
 	switch v := in.(type) {
 	case *Account:
-		m.account.Delete(v.ID)
+		m.account.Del(PaAccount, v.ID)
+		if m.identity != nil {
+			m.identity.Forget(v.ID)
+		}
 		return nil
 	default:
 		return ErrBadDataType
@@ -420,15 +670,23 @@ func (m *Manager) addProvider(in provider.Service) error {
 	if in == nil {
 		return ErrProviderNil
 	}
+	var name string
 	switch pt := in.(type) {
 	case *provider.Identity:
-		m.identity = pt
+		m.identity, name = pt, "identity"
 	case *provider.Presence:
-		m.presence = pt
+		m.presence, name = pt, "presence"
 	case *provider.Note:
-		m.note = pt
+		m.note, name = pt, "note"
 	case *provider.Storage:
-		m.storage = pt
+		m.storage, name = pt, "storage"
+	}
+	if c := in.Client(); c != nil {
+		b := provider.NewBreaker(name, c, m.breaker)
+		if err := in.SetClient(b); err != nil {
+			return err
+		}
+		m.breakers[name] = b
 	}
 	return nil
 }
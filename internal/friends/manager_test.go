@@ -0,0 +1,715 @@
+package friends
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BethesdaNet/friends-go/internal/client"
+	"github.com/BethesdaNet/friends-go/internal/db/redis"
+	"github.com/BethesdaNet/friends-go/internal/friends/status"
+	"github.com/BethesdaNet/friends-go/internal/provider"
+	"github.com/BethesdaNet/friends-go/internal/provider/identity"
+	goredis "github.com/go-redis/redis"
+)
+
+// memClient is an in-memory redis.Client covering the commands the manager uses
+type memClient struct {
+	redis.Client
+	mu    sync.Mutex
+	kv    map[string]string
+	sets  map[string]map[string]bool
+	hash  map[string]map[string]string
+	lists map[string][]string
+	zsets map[string]map[string]float64
+	ttl   map[string]time.Duration
+
+	// cluster rejects multi-key commands across hash slots like a redis cluster
+	cluster bool
+}
+
+func newMemClient() *memClient {
+	return &memClient{
+		kv:    make(map[string]string),
+		sets:  make(map[string]map[string]bool),
+		hash:  make(map[string]map[string]string),
+		lists: make(map[string][]string),
+		zsets: make(map[string]map[string]float64),
+		ttl:   make(map[string]time.Duration),
+	}
+}
+
+// crossSlot returns the error a redis cluster replies with when keys do not
+// share a hash slot
+func (c *memClient) crossSlot(k ...string) error {
+	if !c.cluster {
+		return nil
+	}
+	for _, key := range k[1:] {
+		if redis.Slot(key) != redis.Slot(k[0]) {
+			return errCrossSlot
+		}
+	}
+	return nil
+}
+
+var errCrossSlot = errors.New("CROSSSLOT Keys in request don't hash to the same slot")
+
+func newTestManager() (*Manager, *memClient) {
+	c := newMemClient()
+	c.cluster = true
+	return &Manager{
+		dba:     redis.NewAgent(c, redis.Config{TTL: time.Minute, Clustered: true}),
+		account: client.NewLRU(0, 0),
+		done:    make(chan struct{}),
+	}, c
+}
+
+func (c *memClient) Get(k string) *redis.StringCmd {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if v, ok := c.kv[k]; ok {
+		return goredis.NewStringResult(v, nil)
+	}
+	return goredis.NewStringResult("", goredis.Nil)
+}
+
+func (c *memClient) MGet(k ...string) *redis.SliceCmd {
+	if err := c.crossSlot(k...); err != nil {
+		return goredis.NewSliceResult(nil, err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]interface{}, len(k))
+	for i, key := range k {
+		if v, ok := c.kv[key]; ok {
+			out[i] = v
+		}
+	}
+	return goredis.NewSliceResult(out, nil)
+}
+
+func (c *memClient) Set(k string, v interface{}, ttl time.Duration) *redis.StatusCmd {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setTTL(k, ttl)
+	switch v := v.(type) {
+	case []byte:
+		c.kv[k] = string(v)
+	default:
+		c.kv[k] = fmt.Sprint(v)
+	}
+	return goredis.NewStatusResult("OK", nil)
+}
+
+func (c *memClient) SetNX(k string, v interface{}, ttl time.Duration) *redis.BoolCmd {
+	if c.Exists(k).Val() == 1 {
+		return goredis.NewBoolResult(false, nil)
+	}
+	c.Set(k, v, ttl)
+	return goredis.NewBoolResult(true, nil)
+}
+
+func (c *memClient) Incr(k string) *redis.IntCmd {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n, _ := strconv.ParseInt(c.kv[k], 10, 64)
+	c.kv[k] = strconv.FormatInt(n+1, 10)
+	return goredis.NewIntResult(n+1, nil)
+}
+
+// Eval supports the compare-and-expire and compare-and-delete lease scripts,
+// and the conditional hash delete and set add scripts
+func (c *memClient) Eval(script string, k []string, args ...interface{}) *redis.Cmd {
+	if err := c.crossSlot(k...); err != nil {
+		return goredis.NewCmdResult(nil, err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if strings.Contains(script, "sismember") {
+		v := fmt.Sprint(args[0])
+		for _, key := range k {
+			if c.sets[key][v] {
+				return goredis.NewCmdResult(key, nil)
+			}
+		}
+		if c.sets[k[0]] == nil {
+			c.sets[k[0]] = make(map[string]bool)
+		}
+		c.sets[k[0]][v] = true
+		return goredis.NewCmdResult("", nil)
+	}
+	if strings.Contains(script, "hdel") {
+		var n int64
+		for i := 0; i+1 < len(args); i += 2 {
+			f := fmt.Sprint(args[i])
+			if v, ok := c.hash[k[0]][f]; ok && v == fmt.Sprint(args[i+1]) {
+				delete(c.hash[k[0]], f)
+				n++
+			}
+		}
+		if len(c.hash[k[0]]) == 0 {
+			delete(c.hash, k[0])
+		}
+		return goredis.NewCmdResult(n, nil)
+	}
+	if c.kv[k[0]] != fmt.Sprint(args[0]) {
+		return goredis.NewCmdResult(int64(0), nil)
+	}
+	if !strings.Contains(script, "pexpire") {
+		delete(c.kv, k[0])
+	}
+	return goredis.NewCmdResult(int64(1), nil)
+}
+
+func (c *memClient) Del(k ...string) *redis.IntCmd {
+	if err := c.crossSlot(k...); err != nil {
+		return goredis.NewIntResult(0, err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var n int64
+	for _, key := range k {
+		if c.del(key) {
+			n++
+		}
+	}
+	return goredis.NewIntResult(n, nil)
+}
+
+// setTTL records the ttl of k; a zero ttl persists it
+func (c *memClient) setTTL(k string, ttl time.Duration) {
+	if ttl > 0 {
+		c.ttl[k] = ttl
+	} else {
+		delete(c.ttl, k)
+	}
+}
+
+func (c *memClient) del(k string) bool {
+	delete(c.ttl, k)
+	_, a := c.kv[k]
+	_, b := c.sets[k]
+	_, h := c.hash[k]
+	_, l := c.lists[k]
+	_, z := c.zsets[k]
+	delete(c.kv, k)
+	delete(c.sets, k)
+	delete(c.hash, k)
+	delete(c.lists, k)
+	delete(c.zsets, k)
+	return a || b || h || l || z
+}
+
+func (c *memClient) Exists(k ...string) *redis.IntCmd {
+	if err := c.crossSlot(k...); err != nil {
+		return goredis.NewIntResult(0, err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var n int64
+	for _, key := range k {
+		_, a := c.kv[key]
+		_, b := c.sets[key]
+		_, h := c.hash[key]
+		_, l := c.lists[key]
+		if a || b || h || l {
+			n++
+		}
+	}
+	return goredis.NewIntResult(n, nil)
+}
+
+func (c *memClient) Expire(k string, ttl time.Duration) *redis.BoolCmd {
+	ok := c.Exists(k).Val() == 1
+	if ok {
+		c.mu.Lock()
+		c.setTTL(k, ttl)
+		c.mu.Unlock()
+	}
+	return goredis.NewBoolResult(ok, nil)
+}
+
+func (c *memClient) SAdd(k string, m ...interface{}) *redis.IntCmd {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	set, ok := c.sets[k]
+	if !ok {
+		set = make(map[string]bool)
+		c.sets[k] = set
+	}
+	var n int64
+	for _, v := range m {
+		if s := fmt.Sprint(v); !set[s] {
+			set[s] = true
+			n++
+		}
+	}
+	return goredis.NewIntResult(n, nil)
+}
+
+func (c *memClient) SRem(k string, m ...interface{}) *redis.IntCmd {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var n int64
+	for _, v := range m {
+		if s := fmt.Sprint(v); c.sets[k][s] {
+			delete(c.sets[k], s)
+			n++
+		}
+	}
+	if len(c.sets[k]) == 0 {
+		delete(c.sets, k)
+	}
+	return goredis.NewIntResult(n, nil)
+}
+
+func (c *memClient) SCard(k string) *redis.IntCmd {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return goredis.NewIntResult(int64(len(c.sets[k])), nil)
+}
+
+func (c *memClient) SIsMember(k string, m interface{}) *redis.BoolCmd {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return goredis.NewBoolResult(c.sets[k][fmt.Sprint(m)], nil)
+}
+
+func (c *memClient) SMembers(k string) *redis.StringSliceCmd {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]string, 0, len(c.sets[k]))
+	for v := range c.sets[k] {
+		out = append(out, v)
+	}
+	sort.Strings(out)
+	return goredis.NewStringSliceResult(out, nil)
+}
+
+func (c *memClient) SInter(k ...string) *redis.StringSliceCmd {
+	if err := c.crossSlot(k...); err != nil {
+		return goredis.NewStringSliceResult(nil, err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := []string{}
+	for v := range c.sets[k[0]] {
+		in := true
+		for _, key := range k[1:] {
+			in = in && c.sets[key][v]
+		}
+		if in {
+			out = append(out, v)
+		}
+	}
+	sort.Strings(out)
+	return goredis.NewStringSliceResult(out, nil)
+}
+
+func (c *memClient) SPopN(k string, n int64) *redis.StringSliceCmd {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := []string{}
+	for v := range c.sets[k] {
+		if int64(len(out)) == n {
+			break
+		}
+		out = append(out, v)
+		delete(c.sets[k], v)
+	}
+	if len(c.sets[k]) == 0 {
+		delete(c.sets, k)
+	}
+	return goredis.NewStringSliceResult(out, nil)
+}
+
+func (c *memClient) LPush(k string, v ...interface{}) *redis.IntCmd {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, e := range v {
+		c.lists[k] = append([]string{fmt.Sprint(e)}, c.lists[k]...)
+	}
+	return goredis.NewIntResult(int64(len(c.lists[k])), nil)
+}
+
+func (c *memClient) LLen(k string) *redis.IntCmd {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return goredis.NewIntResult(int64(len(c.lists[k])), nil)
+}
+
+func (c *memClient) LRange(k string, start, stop int64) *redis.StringSliceCmd {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	l := c.lists[k]
+	if stop < 0 || stop >= int64(len(l)) {
+		stop = int64(len(l)) - 1
+	}
+	if start > stop {
+		return goredis.NewStringSliceResult([]string{}, nil)
+	}
+	return goredis.NewStringSliceResult(append([]string{}, l[start:stop+1]...), nil)
+}
+
+func (c *memClient) LRem(k string, n int64, v interface{}) *redis.IntCmd {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out, removed := []string{}, int64(0)
+	for _, e := range c.lists[k] {
+		if e == fmt.Sprint(v) && removed < n {
+			removed++
+			continue
+		}
+		out = append(out, e)
+	}
+	c.lists[k] = out
+	if len(out) == 0 {
+		delete(c.lists, k)
+	}
+	return goredis.NewIntResult(removed, nil)
+}
+
+func (c *memClient) RPop(k string) *redis.StringCmd {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return goredis.NewStringResult(c.rpop(k))
+}
+
+func (c *memClient) rpop(k string) (string, error) {
+	l := c.lists[k]
+	if len(l) == 0 {
+		return "", goredis.Nil
+	}
+	v := l[len(l)-1]
+	if c.lists[k] = l[:len(l)-1]; len(l) == 1 {
+		delete(c.lists, k)
+	}
+	return v, nil
+}
+
+func (c *memClient) RPopLPush(src, dst string) *redis.StringCmd {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, err := c.rpop(src)
+	if err == nil {
+		c.lists[dst] = append([]string{v}, c.lists[dst]...)
+	}
+	return goredis.NewStringResult(v, err)
+}
+
+func (c *memClient) BRPopLPush(src, dst string, _ time.Duration) *redis.StringCmd {
+	return c.RPopLPush(src, dst)
+}
+
+func (c *memClient) ZAdd(k string, m ...goredis.Z) *redis.IntCmd {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.zsets[k] == nil {
+		c.zsets[k] = make(map[string]float64)
+	}
+	for _, z := range m {
+		c.zsets[k][fmt.Sprint(z.Member)] = z.Score
+	}
+	return goredis.NewIntResult(int64(len(m)), nil)
+}
+
+func (c *memClient) ZRangeByScore(k string, by goredis.ZRangeBy) *redis.StringSliceCmd {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	min, _ := strconv.ParseFloat(by.Min, 64)
+	max, _ := strconv.ParseFloat(by.Max, 64)
+	out := []string{}
+	for v, score := range c.zsets[k] {
+		if score >= min && score <= max {
+			out = append(out, v)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return c.zsets[k][out[i]] < c.zsets[k][out[j]] })
+	if by.Count > 0 && int64(len(out)) > by.Count {
+		out = out[:by.Count]
+	}
+	return goredis.NewStringSliceResult(out, nil)
+}
+
+func (c *memClient) ZRem(k string, m ...interface{}) *redis.IntCmd {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var n int64
+	for _, v := range m {
+		if _, ok := c.zsets[k][fmt.Sprint(v)]; ok {
+			delete(c.zsets[k], fmt.Sprint(v))
+			n++
+		}
+	}
+	return goredis.NewIntResult(n, nil)
+}
+
+// flushPresence announces every scheduled presence without waiting for the
+// presence window
+func flushPresence(m *Manager, c *memClient) {
+	c.mu.Lock()
+	for k := range c.zsets[KyPresenceDue] {
+		c.zsets[KyPresenceDue][k] = 0
+	}
+	c.mu.Unlock()
+	m.fanout()
+}
+
+// flushNotes queues every held batch without waiting for the note window
+func flushNotes(m *Manager, c *memClient) {
+	c.mu.Lock()
+	for k := range c.zsets[KyNoteBatchDue] {
+		c.zsets[KyNoteBatchDue][k] = 0
+	}
+	c.mu.Unlock()
+	m.flushBatches()
+}
+
+// popNotes flushes held batches and pops every queued notification oldest
+// first with batches expanded
+func popNotes(m *Manager, c *memClient) []*Queued {
+	flushNotes(m, c)
+	out := []*Queued{}
+	for {
+		raw, err := c.RPop(KyNoteQueue).Result()
+		if err != nil {
+			return out
+		}
+		q := &Queued{}
+		json.Unmarshal([]byte(raw), q)
+		if len(q.Batch) > 0 {
+			out = append(out, q.Batch...)
+			continue
+		}
+		out = append(out, q)
+	}
+}
+
+func (c *memClient) HSet(k, f string, v interface{}) *redis.BoolCmd {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	h, ok := c.hash[k]
+	if !ok {
+		h = make(map[string]string)
+		c.hash[k] = h
+	}
+	_, exists := h[f]
+	h[f] = fmt.Sprint(v)
+	return goredis.NewBoolResult(!exists, nil)
+}
+
+func (c *memClient) HMSet(k string, fields map[string]interface{}) *redis.StatusCmd {
+	for f, v := range fields {
+		c.HSet(k, f, v)
+	}
+	return goredis.NewStatusResult("OK", nil)
+}
+
+func (c *memClient) HDel(k string, f ...string) *redis.IntCmd {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var n int64
+	for _, v := range f {
+		if _, ok := c.hash[k][v]; ok {
+			delete(c.hash[k], v)
+			n++
+		}
+	}
+	if len(c.hash[k]) == 0 {
+		delete(c.hash, k)
+	}
+	return goredis.NewIntResult(n, nil)
+}
+
+func (c *memClient) HGetAll(k string) *redis.StringStringMapCmd {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make(map[string]string, len(c.hash[k]))
+	for f, v := range c.hash[k] {
+		out[f] = v
+	}
+	return goredis.NewStringStringMapResult(out, nil)
+}
+
+/* -------------------------------------------------------------------------- */
+
+func TestStatus(t *testing.T) {
+	m, c := newTestManager()
+	player := "in menus"
+
+	t.Run("Missing", func(t *testing.T) {
+		out := &Status{}
+		if err := m.GetSingleStatus("a", "", "", "", "", out); err != nil {
+			t.Fatal(err)
+		}
+		if out.Enum != status.Offline || out.BUID != "a" {
+			t.Errorf("got %v; want %v", out.Enum, status.Offline)
+		}
+	})
+	/* ------------------------------------------------------------------------ */
+	t.Run("Set", func(t *testing.T) {
+		in := (&Status{Player: &player}).Set(status.Online)
+		if err := m.SetStatus("a", "", "", "", "", in); err != nil {
+			t.Fatal(err)
+		}
+		in = (&Status{}).Set(status.DND)
+		if err := m.SetStatus("a", "fallout", "pc", "", "", in); err != nil {
+			t.Fatal(err)
+		}
+		if n := len(c.sets[fmt.Sprintf(status.PaKeys, "a")]); n != 2 {
+			t.Errorf("got %v; want %v", n, 2)
+		}
+		if n := len(popNotes(m, c)); n != 2 {
+			t.Errorf("got %v; want %v", n, 2)
+		}
+	})
+	/* ------------------------------------------------------------------------ */
+	t.Run("Get", func(t *testing.T) {
+		out := &Status{}
+		if err := m.GetSingleStatus("a", "", "", "", "", out); err != nil {
+			t.Fatal(err)
+		}
+		if out.Enum != status.Online || out.Player == nil || *out.Player != player {
+			t.Errorf("got %+v; want %v %q", out, status.Online, player)
+		}
+	})
+	/* ------------------------------------------------------------------------ */
+	t.Run("Multi", func(t *testing.T) {
+		// statuses of different accounts are on different cluster slots
+		if a, b := redis.Slot(status.Key("a", "", "", false)), redis.Slot(status.Key("b", "", "", false)); a == b {
+			t.Fatalf("got %v,%v; want different slots", a, b)
+		}
+		out, err := m.GetMultiStatus("a,b,a", "", "", "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(out) != 2 {
+			t.Fatalf("got %v; want %v", len(out), 2)
+		}
+		if out["a"].Enum != status.Online || out["b"].Enum != status.Offline {
+			t.Errorf("got %v,%v; want %v,%v", out["a"].Enum, out["b"].Enum, status.Online, status.Offline)
+		}
+	})
+	/* ------------------------------------------------------------------------ */
+	t.Run("Group", func(t *testing.T) {
+		group, err := m.GetBUID("a", "", "", "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(group.Key) != 2 {
+			t.Fatalf("got %v; want %v", group.Key, 2)
+		}
+		if s := group.Data[status.Key("a", "fallout", "pc", false)].(*Status); s.Enum != status.DND {
+			t.Errorf("got %v; want %v", s.Enum, status.DND)
+		}
+
+		// expired keys are returned offline and pruned from the index
+		delete(c.kv, status.Key("a", "fallout", "pc", false))
+		if group, err = m.GetBUID("a", "", "", "", ""); err != nil {
+			t.Fatal(err)
+		}
+		if s := group.Data[status.Key("a", "fallout", "pc", false)].(*Status); s.Enum != status.Offline {
+			t.Errorf("got %v; want %v", s.Enum, status.Offline)
+		}
+		if n := len(c.sets[fmt.Sprintf(status.PaKeys, "a")]); n != 1 {
+			t.Errorf("got %v; want %v", n, 1)
+		}
+	})
+	/* ------------------------------------------------------------------------ */
+	t.Run("Del", func(t *testing.T) {
+		if err := m.DelStatus("a", "", ""); err != nil {
+			t.Fatal(err)
+		}
+		out := &Status{}
+		if err := m.GetSingleStatus("a", "", "", "", "", out); err != nil {
+			t.Fatal(err)
+		}
+		if out.Enum != status.Offline {
+			t.Errorf("got %v; want %v", out.Enum, status.Offline)
+		}
+		if _, err := m.GetBUID("a", "", "", "", ""); err != nil {
+			t.Errorf("got %v; want %v", err, nil)
+		}
+	})
+	/* ------------------------------------------------------------------------ */
+	t.Run("Blocked", func(t *testing.T) {
+		if err := m.SetStatus("b", "", "", "", "", (&Status{}).Set(status.Online)); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Block("b", "c"); err != nil {
+			t.Fatal(err)
+		}
+		out, err := m.ViewStatuses("c", []string{"b"}, "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out["b"].Enum != status.Offline {
+			t.Errorf("got %v; want %v", out["b"].Enum, status.Offline)
+		}
+	})
+}
+
+func TestAccountCache(t *testing.T) {
+	m, _ := newTestManager()
+
+	t.Run("Invalidate", func(t *testing.T) {
+		m.store(&Account{ID: "a", Name: "alice"})
+		m.store(&Account{ID: "b", Name: "bob"})
+		got, err := m.GetAccounts("a", "b")
+		if err != nil || len(got) != 2 || got["a"].Name != "alice" {
+			t.Fatalf("got %v,%v; want %v", got, err, "a,b")
+		}
+		m.Invalidate("a")
+		if m.load(&Account{ID: "a"}) {
+			t.Errorf("got %v; want %v", true, false)
+		}
+		if !m.load(&Account{ID: "b"}) {
+			t.Errorf("got %v; want %v", false, true)
+		}
+	})
+	/* ------------------------------------------------------------------------ */
+	t.Run("Merged", func(t *testing.T) {
+		m.store(&Account{ID: "b", Name: "bob", State: identity.StateMerged})
+		if m.load(&Account{ID: "b"}) {
+			t.Errorf("got %v; want %v", true, false)
+		}
+		m.store(&Account{ID: "c", State: identity.StateMerged})
+		if m.load(&Account{ID: "c"}) {
+			t.Errorf("got %v; want %v", true, false)
+		}
+	})
+}
+
+func TestAccountLookup(t *testing.T) {
+	m, _ := newTestManager()
+	t.Run("Panic", func(t *testing.T) {
+		// an identity provider without a client panics on lookup
+		m.identity = &provider.Identity{}
+		func() {
+			defer func() { recover() }()
+			m.GetAccounts("a")
+		}()
+
+		done := make(chan struct{})
+		go func() {
+			own, wait := m.lookups.join([]string{"a"})
+			if len(own) != 1 || len(wait) != 0 {
+				t.Errorf("got %v,%v; want %v,%v", own, wait, []string{"a"}, nil)
+			}
+			m.lookups.finish("a", nil, nil)
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("got lookup blocked; want lookup owned")
+		}
+	})
+}
@@ -0,0 +1,337 @@
+package friends
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/BethesdaNet/friends-go/internal/db/redis"
+)
+
+// ErrSelfMerge returned when an account is merged into itself
+var ErrSelfMerge = errors.New("cannot merge an account into itself")
+
+// Merge moves the friend graph of from onto to once identity merged from into
+// to. Blocks are moved first so friendships and requests between to and an
+// account on either side of a block are dropped rather than moved. Edges to
+// already held by to are kept once, friendships with the earliest date. Every
+// step removes what it moved from the merged account, so merging again after a
+// failure finishes the merge.
+func (m *Manager) Merge(from, to string) error {
+	switch {
+	case from == "" || to == "":
+		return ErrBadBUID
+	case from == to:
+		return ErrSelfMerge
+	}
+	for _, step := range []func(from, to string) error{
+		m.mergeBlocks,
+		m.mergeFriends,
+		m.mergeRequests,
+		m.mergeSettings,
+	} {
+		if err := step(from, to); err != nil {
+			return err
+		}
+	}
+	m.Invalidate(from, to)
+	return m.queueSuggestions(to)
+}
+
+// mergeBlocks moves the blocks made by and against from onto to and severs
+// to from the accounts on the other side
+func (m *Manager) mergeBlocks(from, to string) error {
+	blocks, err := m.dba.SMembers(fmt.Sprintf(PaBlocks, from))
+	if err != nil {
+		return err
+	}
+	for _, id := range blocks {
+		if err := m.dba.SRem(fmt.Sprintf(PaBlockedBy, id), from); err != nil {
+			return err
+		}
+		if id == to {
+			continue
+		}
+		if err := m.dba.SAdd(fmt.Sprintf(PaBlocks, to), id); err != nil {
+			return err
+		}
+		if err := m.dba.SAdd(fmt.Sprintf(PaBlockedBy, id), to); err != nil {
+			return err
+		}
+		if err := m.sever(to, id); err != nil {
+			return err
+		}
+	}
+
+	blockers, err := m.dba.SMembers(fmt.Sprintf(PaBlockedBy, from))
+	if err != nil {
+		return err
+	}
+	for _, id := range blockers {
+		if err := m.dba.SRem(fmt.Sprintf(PaBlocks, id), from); err != nil {
+			return err
+		}
+		if id == to {
+			continue
+		}
+		if err := m.dba.SAdd(fmt.Sprintf(PaBlocks, id), to); err != nil {
+			return err
+		}
+		if err := m.dba.SAdd(fmt.Sprintf(PaBlockedBy, to), id); err != nil {
+			return err
+		}
+		if err := m.sever(id, to); err != nil {
+			return err
+		}
+	}
+	return m.dba.Del(fmt.Sprintf(PaBlocks, from), fmt.Sprintf(PaBlockedBy, from))
+}
+
+// mergeFriends re-points the friendships of from to to along with the mutes on
+// either side of each friendship
+func (m *Manager) mergeFriends(from, to string) error {
+	friends, err := m.friends(from)
+	if err != nil {
+		return err
+	}
+	blocked, err := m.blocked(to)
+	if err != nil {
+		return err
+	}
+	mine, err := m.dba.HGetAll(fmt.Sprintf(PaFriendsSince, to))
+	if err != nil {
+		return err
+	}
+	muted, err := m.dba.SMembers(fmt.Sprintf(PaMuted, from))
+	if err != nil {
+		return err
+	}
+	mutes := make(map[string]bool, len(muted))
+	for _, id := range muted {
+		mutes[id] = true
+	}
+
+	for _, f := range friends {
+		// the counterpart edge is removed whether or not the friendship moves
+		if err := m.dba.SRem(fmt.Sprintf(PaFriends, f.BUID), from); err != nil {
+			return err
+		}
+		if err := m.dba.HDel(fmt.Sprintf(PaFriendsSince, f.BUID), from); err != nil {
+			return err
+		}
+		mutedBy, err := m.dba.SIsMember(fmt.Sprintf(PaMuted, f.BUID), from)
+		if err != nil {
+			return err
+		}
+		if err := m.dba.SRem(fmt.Sprintf(PaMuted, f.BUID), from); err != nil {
+			return err
+		}
+		if f.BUID == to || blocked[f.BUID] {
+			continue
+		}
+
+		since := f.Since
+		if ts, ok := mine[f.BUID]; ok {
+			sec, _ := strconv.ParseInt(ts, 10, 64)
+			if t := time.Unix(sec, 0); t.Before(since) {
+				since = t
+			}
+		}
+		if err := m.link(to, f.BUID, since); err != nil {
+			return err
+		}
+		if mutedBy {
+			if err := m.dba.SAdd(fmt.Sprintf(PaMuted, f.BUID), to); err != nil {
+				return err
+			}
+		}
+		if mutes[f.BUID] {
+			if err := m.dba.SAdd(fmt.Sprintf(PaMuted, to), f.BUID); err != nil {
+				return err
+			}
+		}
+	}
+	return m.dba.Del(fmt.Sprintf(PaFriends, from), fmt.Sprintf(PaFriendsSince, from), fmt.Sprintf(PaMuted, from))
+}
+
+// mergeRequests re-points the pending requests sent to and by from
+func (m *Manager) mergeRequests(from, to string) error {
+	in, err := m.dba.SMembers(fmt.Sprintf(PaRequestsIn, from))
+	if err != nil {
+		return err
+	}
+	for _, id := range in {
+		if err := m.moveRequest(from, id, &Request{From: id, To: to}); err != nil {
+			return err
+		}
+	}
+	out, err := m.dba.SMembers(fmt.Sprintf(PaRequestsOut, from))
+	if err != nil {
+		return err
+	}
+	for _, id := range out {
+		if err := m.moveRequest(id, from, &Request{From: to, To: id}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// moveRequest replaces the request stored for recipient to from sender with
+// next, keeping the time it was sent and when it expires. The request is
+// dropped if next would be between friends, blocked accounts, the same
+// account, or duplicates a request already pending in either direction.
+func (m *Manager) moveRequest(to, from string, next *Request) error {
+	req, err := m.GetRequest(to, from)
+	switch err {
+	case nil:
+	case ErrRequestNotFound:
+		return m.delRequest(to, from)
+	default:
+		return err
+	}
+	if err := m.delRequest(to, from); err != nil {
+		return err
+	}
+	if next.From == next.To {
+		return nil
+	}
+	for _, check := range []func(a, b string) (bool, error){m.IsBlocked, m.IsFriend} {
+		if ok, err := check(next.From, next.To); err != nil || ok {
+			return err
+		}
+	}
+	for _, key := range []string{fmt.Sprintf(PaRequest, next.To, next.From), fmt.Sprintf(PaRequest, next.From, next.To)} {
+		if ok, err := m.dba.Exists(key); err != nil || ok {
+			return err
+		}
+	}
+
+	ttl := m.requestTTL
+	if ttl <= 0 {
+		ttl = DefaultRequestTTL
+	}
+	if ttl -= time.Since(req.Time); ttl <= 0 {
+		return nil
+	}
+	next.Time = req.Time
+	return m.putRequest(next, ttl)
+}
+
+// mergeSettings moves the privacy settings, dismissed suggestions, products,
+// and recently played lists of from onto to. Privacy settings of to are kept
+// if it has any.
+func (m *Manager) mergeSettings(from, to string) error {
+	switch raw, err := m.dba.GetBytes(fmt.Sprintf(PaPrivacy, from)); err {
+	case nil:
+		ok, err := m.dba.Exists(fmt.Sprintf(PaPrivacy, to))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			if err := m.dba.SetBytesExpire(fmt.Sprintf(PaPrivacy, to), raw, 0); err != nil {
+				return err
+			}
+		}
+	case redis.ErrBadKey:
+	default:
+		return err
+	}
+
+	for _, pattern := range []string{PaDismissed, PaProducts} {
+		ids, err := m.dba.SMembers(fmt.Sprintf(pattern, from))
+		if err != nil {
+			return err
+		}
+		keep := make([]string, 0, len(ids))
+		for _, id := range ids {
+			if id != to {
+				keep = append(keep, id)
+			}
+		}
+		if len(keep) > 0 {
+			if err := m.dba.SAdd(fmt.Sprintf(pattern, to), keep...); err != nil {
+				return err
+			}
+		}
+	}
+	if err := m.mergePlayed(from, to); err != nil {
+		return err
+	}
+
+	// keys of each account share a cluster slot only with keys of that account
+	if err := m.dba.Del(fmt.Sprintf(PaSuggestions, to)); err != nil {
+		return err
+	}
+	if _, err := m.dba.ZRem(KySuggestRefresh, from); err != nil {
+		return err
+	}
+	if err := m.dba.SRem(KySuggestQueue, from); err != nil {
+		return err
+	}
+	return m.dba.Del(
+		fmt.Sprintf(PaPrivacy, from),
+		fmt.Sprintf(PaDismissed, from),
+		fmt.Sprintf(PaProducts, from),
+		fmt.Sprintf(PaPlayed, from),
+		fmt.Sprintf(PaSuggestions, from),
+		fmt.Sprintf(PaAnnounced, from),
+	)
+}
+
+// mergePlayed moves the recently played list of from onto to and re-points the
+// entries for from in the lists of the accounts it played with. The most recent
+// entry wins where both accounts played with the same account.
+func (m *Manager) mergePlayed(from, to string) error {
+	rows, err := m.dba.HGetAll(fmt.Sprintf(PaPlayed, from))
+	if err != nil || len(rows) == 0 {
+		return err
+	}
+	mine, err := m.dba.HGetAll(fmt.Sprintf(PaPlayed, to))
+	if err != nil {
+		return err
+	}
+	for id, row := range rows {
+		key := fmt.Sprintf(PaPlayed, id)
+		theirs, err := m.dba.HGetAll(key)
+		if err != nil {
+			return err
+		}
+		if err := m.dba.HDel(key, from); err != nil {
+			return err
+		}
+		if id == to {
+			continue
+		}
+		if newer(row, mine[id]) {
+			if err := m.dba.HSet(fmt.Sprintf(PaPlayed, to), id, row); err != nil {
+				return err
+			}
+		}
+		if back, ok := theirs[from]; ok && newer(back, theirs[to]) {
+			if err := m.dba.HSet(key, to, back); err != nil {
+				return err
+			}
+		}
+	}
+	if err := m.dba.Expire(fmt.Sprintf(PaPlayed, to), m.window()); err != nil {
+		return err
+	}
+	_, err = m.recent(to)
+	return err
+}
+
+// newer returns true if the played entry a is more recent than b or b is not
+// a valid entry
+func newer(a, b string) bool {
+	pa, pb := &Played{}, &Played{}
+	if err := json.Unmarshal([]byte(b), pb); err != nil {
+		return true
+	}
+	if err := json.Unmarshal([]byte(a), pa); err != nil {
+		return false
+	}
+	return pa.Time.After(pb.Time)
+}
@@ -0,0 +1,94 @@
+package friends
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	m, c := newTestManager()
+	for _, edge := range [][2]string{{"o", "a"}, {"o", "b"}, {"o", "s"}, {"s", "a"}, {"s", "x"}} {
+		if err := m.addFriend(edge[0], edge[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.HSet(fmt.Sprintf(PaFriendsSince, "o"), "a", "100")
+	c.HSet(fmt.Sprintf(PaFriendsSince, "a"), "o", "100")
+	for _, req := range [][2]string{{"c", "o"}, {"o", "d"}, {"e", "o"}, {"e", "s"}} {
+		if _, err := m.SendRequest(req[0], req[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.Block("o", "x")
+	m.Block("y", "o")
+	m.Mute("b", "o")
+	m.SetPrivacy("o", &Privacy{Requests: AudienceNobody, Presence: AudienceFriends})
+
+	if err := m.Merge("o", "s"); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Friends", func(t *testing.T) {
+		page, err := m.GetFriends("s", Page{Sort: SortAdded})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := map[string]int64{}
+		for _, f := range page.Friends {
+			got[f.BUID] = f.Since.Unix()
+		}
+		if len(got) != 2 || got["a"] != 100 || got["b"] == 0 {
+			t.Errorf("got %v; want %v", got, "a,b")
+		}
+		for _, id := range []string{"a", "b"} {
+			if ok, _ := m.IsFriend(id, "o"); ok {
+				t.Errorf("got %v; want %v", ok, false)
+			}
+			if ok, _ := m.IsFriend(id, "s"); !ok {
+				t.Errorf("got %v; want %v", ok, true)
+			}
+		}
+		if muted, _ := m.dba.SIsMember(fmt.Sprintf(PaMuted, "b"), "s"); !muted {
+			t.Errorf("got %v; want %v", muted, true)
+		}
+	})
+	t.Run("Blocks", func(t *testing.T) {
+		if ok, _ := m.IsFriend("s", "x"); ok {
+			t.Errorf("got %v; want %v", ok, false)
+		}
+		for _, edge := range [][2]string{{"s", "x"}, {"y", "s"}} {
+			if ok, _ := m.dba.SIsMember(fmt.Sprintf(PaBlocks, edge[0]), edge[1]); !ok {
+				t.Errorf("%v: got %v; want %v", edge, ok, true)
+			}
+		}
+		if ok, _ := m.dba.SIsMember(fmt.Sprintf(PaBlocks, "y"), "o"); ok {
+			t.Errorf("got %v; want %v", ok, false)
+		}
+	})
+	t.Run("Requests", func(t *testing.T) {
+		reqs, err := m.GetRequests("s")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(reqs.Incoming) != 2 || len(reqs.Outgoing) != 1 || reqs.Outgoing[0].To != "d" {
+			t.Errorf("got %+v; want %v in, %v out", reqs, 2, 1)
+		}
+		if n, _ := m.dba.SCard(fmt.Sprintf(PaRequestsOut, "e")); n != 1 {
+			t.Errorf("got %v; want %v", n, 1)
+		}
+		if reqs, _ := m.GetRequests("o"); len(reqs.Incoming)+len(reqs.Outgoing) != 0 {
+			t.Errorf("got %+v; want none", reqs)
+		}
+	})
+	t.Run("Settings", func(t *testing.T) {
+		if p, _ := m.GetPrivacy("s"); p.Requests != AudienceNobody {
+			t.Errorf("got %+v; want %v", p, AudienceNobody)
+		}
+		if err := m.Merge("o", "s"); err != nil {
+			t.Errorf("got %v; want %v", err, nil)
+		}
+		if err := m.Merge("s", "s"); err != ErrSelfMerge {
+			t.Errorf("got %v; want %v", err, ErrSelfMerge)
+		}
+	})
+}
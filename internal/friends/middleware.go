@@ -0,0 +1,143 @@
+package friends
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/BethesdaNet/friends-go/internal/platform"
+	"github.com/go-chi/chi/middleware"
+)
+
+// Authenticate populates the request State from the platform headers, and after
+// validating it attaches the State to the request context. Requests without a
+// valid caller are rejected in the platform error envelope.
+func Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state := NewState(r)
+		if err := state.Check(); err != nil {
+			platform.Write(w, errStatus[err], err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithState(r.Context(), state)))
+	})
+}
+
+// VerifyKey verifies the encoded bnet key locally and applies the decoded scope,
+// product, and service headers to the request. It must run before Authenticate
+// so that State is populated from the verified values.
+func VerifyKey(v *platform.Verifier) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := v.Apply(r); err != nil {
+				platform.Write(w, http.StatusUnauthorized, err)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// KeyKindServer is the key type (Key.Kind) of game server keys
+const KeyKindServer = "server"
+
+// ErrNotServer returned when a server route is called without a server key
+var ErrNotServer = errors.New("server key required")
+
+// AuthenticateServer only allows requests sent with a server key in
+// HeaderKeyServer. The key is verified locally when a verifier is configured,
+// otherwise it must match the configured server key. Every request is rejected
+// when neither is set.
+func AuthenticateServer(v *platform.Verifier, key string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			raw := r.Header.Get(platform.HeaderKeyServer)
+			ok := false
+			switch {
+			case raw == "":
+			case v != nil:
+				k, err := v.Verify(raw)
+				if err != nil {
+					platform.Write(w, http.StatusUnauthorized, err)
+					return
+				}
+				ok = k.Kind == KeyKindServer
+			default:
+				ok = sameKey(raw, key)
+			}
+			if !ok {
+				platform.Write(w, errStatus[ErrNotServer], ErrNotServer)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ErrNotService returned when a private route is called without the service key
+var ErrNotService = errors.New("service key required")
+
+// AuthenticateService only allows requests sent with the configured service key
+// in HeaderKeyMaster. Every request is rejected when no service key is set.
+func AuthenticateService(key string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !sameKey(r.Header.Get(platform.HeaderKeyMaster), key) {
+				platform.Write(w, errStatus[ErrNotService], ErrNotService)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// sameKey compares raw with key in constant time. An empty key matches nothing
+// so an unset key fails closed.
+func sameKey(raw, key string) bool {
+	return key != "" && subtle.ConstantTimeCompare([]byte(raw), []byte(key)) == 1
+}
+
+// HandleErrors recovers from panics raised by handlers and returns an internal
+// error to the client in the platform envelope
+func HandleErrors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if v := recover(); v != nil && v != http.ErrAbortHandler {
+				log.Printf("panic: %s %s: %v\n%s", r.Method, r.URL.Path, v, debug.Stack())
+				platform.Write(w, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Logger writes one line per request containing the response status, duration,
+// and caller once the handler returns
+func Logger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		start := time.Now()
+		defer func() {
+			const format = `"rid":%q,"method":%q,"path":%q,"status":%d,"bytes":%d,"ms":%.3f,"buid":%q,"product":%q`
+			log.Printf(format,
+				middleware.GetReqID(r.Context()), r.Method, r.URL.Path, ww.Status(), ww.BytesWritten(),
+				float64(time.Since(start))/float64(time.Millisecond),
+				r.Header.Get(platform.HeaderBUID), r.Header.Get(platform.HeaderProduct),
+			)
+		}()
+		next.ServeHTTP(ww, r)
+	})
+}
+
+// NotFound returns the platform envelope for unknown routes
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	platform.Write(w, http.StatusNotFound, http.StatusText(http.StatusNotFound))
+}
+
+// MethodNotAllowed returns the platform envelope for unsupported methods
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	platform.Write(w, http.StatusMethodNotAllowed, http.StatusText(http.StatusMethodNotAllowed))
+}
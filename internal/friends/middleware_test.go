@@ -0,0 +1,98 @@
+package friends
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/BethesdaNet/friends-go/internal/platform"
+)
+
+func TestAuthenticate(t *testing.T) {
+	var got *State
+	h := Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := GetState(r)
+		got = &s
+	}))
+	for _, tc := range []struct {
+		buid, product string
+		code          int
+	}{
+		{"a", "fallout", http.StatusOK},
+		{"", "", http.StatusUnauthorized},
+		{"a}", "", http.StatusBadRequest},
+		{"a", "friends", http.StatusBadRequest},
+	} {
+		got = nil
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(platform.HeaderBUID, tc.buid)
+		r.Header.Set(platform.HeaderSession, "s")
+		r.Header.Set(platform.HeaderProduct, tc.product)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tc.code {
+			t.Errorf("%q: got %v; want %v", tc.buid, w.Code, tc.code)
+		}
+		switch {
+		case tc.code != http.StatusOK && got != nil:
+			t.Errorf("%q: got %+v; want handler not called", tc.buid, got)
+		case tc.code == http.StatusOK && (got == nil || got.BUID != tc.buid || got.Product != tc.product):
+			t.Errorf("%q: got %+v; want state of %v", tc.buid, got, tc.buid)
+		}
+	}
+
+	// requests which did not pass through Authenticate carry no state
+	if s := GetState(httptest.NewRequest(http.MethodGet, "/", nil)); s != (State{}) {
+		t.Errorf("got %+v; want %+v", s, State{})
+	}
+}
+
+func TestPrivateRoutes(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		method, path string
+		private      bool
+		key, given   string
+		code         int
+	}{
+		{"public task", http.MethodPost, "/private/v3/accounts/invalidate", false, "k", "k", http.StatusNotFound},
+		{"no key set", http.MethodPost, "/private/v3/accounts/invalidate", true, "", "", http.StatusUnauthorized},
+		{"no key sent", http.MethodPost, "/private/v3/accounts/invalidate", true, "k", "", http.StatusUnauthorized},
+		{"wrong key", http.MethodPost, "/private/v3/accounts/invalidate", true, "k", "x", http.StatusUnauthorized},
+		{"service key", http.MethodPost, "/private/v3/accounts/invalidate", true, "k", "k", http.StatusBadRequest},
+		{"merge", http.MethodPost, "/private/v3/accounts/merge", true, "k", "", http.StatusUnauthorized},
+		{"export", http.MethodGet, "/private/v3/accounts/a/export", true, "k", "", http.StatusUnauthorized},
+		{"erase", http.MethodPost, "/private/v3/accounts/a/erase", true, "k", "x", http.StatusUnauthorized},
+	} {
+		f := &Friends{config: Config{Private: tc.private, ServiceKey: tc.key}}
+		r := httptest.NewRequest(tc.method, tc.path, nil)
+		r.Header.Set(platform.HeaderKeyMaster, tc.given)
+		w := httptest.NewRecorder()
+		f.Routes().ServeHTTP(w, r)
+		if w.Code != tc.code {
+			t.Errorf("%s: got %v; want %v", tc.name, w.Code, tc.code)
+		}
+	}
+}
+
+func TestServerRoutes(t *testing.T) {
+	for _, tc := range []struct {
+		name, key, given, kind string
+		code                   int
+	}{
+		{"no key set", "", "x", "server", http.StatusUnauthorized},
+		{"no key sent", "k", "", "server", http.StatusUnauthorized},
+		{"wrong key", "k", "x", "server", http.StatusUnauthorized},
+		{"server key", "k", "k", "", http.StatusBadRequest},
+	} {
+		f := &Friends{config: Config{ServerKey: tc.key}}
+		r := httptest.NewRequest(http.MethodPost, "/v3/played", nil)
+		r.Header.Set(platform.HeaderKeyServer, tc.given)
+		r.Header.Set(platform.HeaderService, tc.kind)
+		w := httptest.NewRecorder()
+		f.Routes().ServeHTTP(w, r)
+		if w.Code != tc.code {
+			t.Errorf("%s: got %v; want %v", tc.name, w.Code, tc.code)
+		}
+	}
+}
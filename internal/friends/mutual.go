@@ -0,0 +1,210 @@
+package friends
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultDegree used when the client does not provide a connection degree
+	DefaultDegree = 2
+
+	// MaxDegree is the furthest connection degree that may be requested. Each
+	// degree multiplies the friend sets read, so the graph is not walked further.
+	MaxDegree = 3
+
+	// MaxConnectionSets limits how many friend sets are read for one query
+	MaxConnectionSets = 500
+
+	// DefaultConnectionLimit used when the client does not provide a limit
+	DefaultConnectionLimit = 50
+
+	// MaxConnectionLimit is the largest number of connections returned
+	MaxConnectionLimit = 200
+)
+
+// ErrBadDegree returned when the requested connection degree is out of range
+var ErrBadDegree = errors.New("bad degree")
+
+// Connection is an account reachable through the friend graph that is not yet
+// a friend of the caller
+type Connection struct {
+	BUID   string `json:"buid"`
+	Name   string `json:"username"`
+	Degree int    `json:"degree"`
+
+	// Mutual is the number of friends shared with the caller
+	Mutual int `json:"mutual"`
+}
+
+// Mutual returns a page of the friends buid shares with other computed from the
+// intersection of both friend sets. Accounts blocking or blocked by buid are
+// left out, and nothing is returned if buid and other block each other. The
+// page is empty unless other shows its friends to buid, so the friends of an
+// account which is not searchable cannot be learned one intersection at a time.
+func (m *Manager) Mutual(buid, other string, p Page) (*FriendPage, error) {
+	if buid == "" || other == "" || buid == other {
+		return nil, ErrBadBUID
+	}
+	if ok, err := m.IsBlocked(buid, other); err != nil {
+		return nil, err
+	} else if ok {
+		return nil, ErrBlocked
+	}
+	if shows, err := m.showsFriends(buid, other); err != nil {
+		return nil, err
+	} else if !shows[other] {
+		return m.page(buid, []*Friend{}, p)
+	}
+	ids, err := m.dba.SInter(fmt.Sprintf(PaFriends, buid), fmt.Sprintf(PaFriends, other))
+	if err != nil {
+		return nil, err
+	}
+	blocked, err := m.blocked(buid)
+	if err != nil {
+		return nil, err
+	}
+	since, err := m.dba.HGetAll(fmt.Sprintf(PaFriendsSince, buid))
+	if err != nil {
+		return nil, err
+	}
+	friends := make([]*Friend, 0, len(ids))
+	for _, id := range ids {
+		if blocked[id] {
+			continue
+		}
+		sec, _ := strconv.ParseInt(since[id], 10, 64)
+		friends = append(friends, &Friend{BUID: id, Since: time.Unix(sec, 0).UTC()})
+	}
+	return m.page(buid, friends, p)
+}
+
+// Connections returns accounts within degree hops of buid in the friend graph
+// ordered by degree and then by the number of mutual friends. Existing friends
+// and blocked accounts are left out, as are accounts which are not searchable or
+// would not accept a friend request from buid.
+func (m *Manager) Connections(buid string, degree, limit int) ([]*Connection, error) {
+	if buid == "" {
+		return nil, ErrBadBUID
+	}
+	if degree == 0 {
+		degree = DefaultDegree
+	}
+	if degree < 2 || degree > MaxDegree {
+		return nil, ErrBadDegree
+	}
+	if limit <= 0 {
+		limit = DefaultConnectionLimit
+	}
+	if limit > MaxConnectionLimit {
+		limit = MaxConnectionLimit
+	}
+
+	friends, err := m.dba.SMembers(fmt.Sprintf(PaFriends, buid))
+	if err != nil {
+		return nil, err
+	}
+	seen, err := m.blocked(buid)
+	if err != nil {
+		return nil, err
+	}
+	seen[buid] = true
+	for _, id := range friends {
+		seen[id] = true
+	}
+
+	// walk the graph one degree at a time; the mutual count of a second degree
+	// connection is the number of the callers friend sets it was found in. Past
+	// the callers friends only the friend sets of accounts showing their friends
+	// to the caller are walked.
+	found := map[string]*Connection{}
+	frontier, reads := friends, 0
+	for d := 2; d <= degree && len(frontier) > 0; d++ {
+		var shows map[string]bool
+		if d > 2 {
+			if shows, err = m.showsFriends(buid, frontier...); err != nil {
+				return nil, err
+			}
+		}
+		next := []string{}
+		for _, id := range frontier {
+			if shows != nil && !shows[id] {
+				continue
+			}
+			if reads == MaxConnectionSets {
+				break
+			}
+			reads++
+			ids, err := m.dba.SMembers(fmt.Sprintf(PaFriends, id))
+			if err != nil {
+				return nil, err
+			}
+			for _, c := range ids {
+				if seen[c] {
+					continue
+				}
+				if conn, ok := found[c]; ok {
+					if conn.Degree == 2 && d == 2 {
+						conn.Mutual++
+					}
+					continue
+				}
+				found[c] = &Connection{BUID: c, Degree: d}
+				if d == 2 {
+					found[c].Mutual = 1
+				}
+				next = append(next, c)
+			}
+		}
+		frontier = next
+	}
+
+	ids := make([]string, 0, len(found))
+	for id := range found {
+		ids = append(ids, id)
+	}
+	settings, err := m.privacy(ids...)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*Connection, 0, len(found))
+	for _, c := range found {
+		if p := settings[c.BUID]; p.Searchable && p.allowsRequest(c.Mutual) {
+			out = append(out, c)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.Degree != b.Degree {
+			return a.Degree < b.Degree
+		}
+		if a.Mutual != b.Mutual {
+			return a.Mutual > b.Mutual
+		}
+		return a.BUID < b.BUID
+	})
+	if len(out) > limit {
+		out = out[:limit]
+	}
+
+	if len(out) > 0 && m.identity != nil {
+		ids := make([]string, len(out))
+		for i, c := range out {
+			ids[i] = c.BUID
+		}
+		accounts, err := m.GetAccounts(ids...)
+		if err != nil {
+			log.Printf("manager: connections: %v", err)
+		}
+		for _, c := range out {
+			if a, ok := accounts[c.BUID]; ok {
+				c.Name = a.Name
+			}
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,114 @@
+package friends
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMutual(t *testing.T) {
+	m, _ := newTestManager()
+	for _, edge := range [][2]string{{"a", "b"}, {"a", "c"}, {"a", "d"}, {"e", "b"}, {"e", "c"}, {"e", "f"}, {"f", "g"}, {"b", "c"}} {
+		if err := m.addFriend(edge[0], edge[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("Mutual", func(t *testing.T) {
+		page, err := m.Mutual("a", "e", Page{Sort: SortName})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.Total != 2 || page.Friends[0].BUID != "b" || page.Friends[1].BUID != "c" {
+			t.Errorf("got %+v; want %v", page.Friends, "b,c")
+		}
+	})
+	/* ------------------------------------------------------------------------ */
+	t.Run("Privacy", func(t *testing.T) {
+		defer m.SetPrivacy("e", DefaultPrivacy())
+		for _, tc := range []struct {
+			name   string
+			p      *Privacy
+			viewer string
+			want   int
+		}{
+			{"searchable", &Privacy{Requests: AudienceEveryone, Presence: AudienceNobody, Searchable: true}, "a", 2},
+			{"hidden", &Privacy{Requests: AudienceEveryone, Presence: AudienceEveryone}, "a", 0},
+			{"hidden friend", &Privacy{Requests: AudienceEveryone, Presence: AudienceNobody}, "b", 1},
+		} {
+			if err := m.SetPrivacy("e", tc.p); err != nil {
+				t.Fatal(err)
+			}
+			page, err := m.Mutual(tc.viewer, "e", Page{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if page.Total != tc.want {
+				t.Errorf("%s: got %v; want %v", tc.name, page.Total, tc.want)
+			}
+		}
+	})
+	/* ------------------------------------------------------------------------ */
+	t.Run("Connections", func(t *testing.T) {
+		out, err := m.Connections("a", 3, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(out) != 2 {
+			t.Fatalf("got %v; want %v", len(out), 2)
+		}
+		if c := out[0]; c.BUID != "e" || c.Degree != 2 || c.Mutual != 2 {
+			t.Errorf("got %+v; want %v", c, "e")
+		}
+		if c := out[1]; c.BUID != "f" || c.Degree != 3 {
+			t.Errorf("got %+v; want %v", c, "f")
+		}
+		if _, err := m.Connections("a", 4, 0); err != ErrBadDegree {
+			t.Errorf("got %v; want %v", err, ErrBadDegree)
+		}
+	})
+	/* ------------------------------------------------------------------------ */
+	t.Run("Discovery", func(t *testing.T) {
+		defer m.SetPrivacy("e", DefaultPrivacy())
+		defer m.SetPrivacy("f", DefaultPrivacy())
+		for _, tc := range []struct {
+			e, f *Privacy
+			want string
+		}{
+			// friends of an account hiding them are not walked past the callers
+			// friends, so f is not found through e
+			{&Privacy{Requests: AudienceEveryone, Presence: AudienceEveryone}, DefaultPrivacy(), ""},
+			{DefaultPrivacy(), &Privacy{Requests: AudienceFriendsOfFriends, Presence: AudienceEveryone, Searchable: true}, "e"},
+			{&Privacy{Requests: AudienceNobody, Presence: AudienceEveryone, Searchable: true}, DefaultPrivacy(), "f"},
+		} {
+			m.SetPrivacy("e", tc.e)
+			m.SetPrivacy("f", tc.f)
+			out, err := m.Connections("a", 3, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, c := range out {
+				got = append(got, c.BUID)
+			}
+			if strings.Join(got, ",") != tc.want {
+				t.Errorf("got %v; want %v", got, tc.want)
+			}
+		}
+	})
+	/* ------------------------------------------------------------------------ */
+	t.Run("Blocked", func(t *testing.T) {
+		if err := m.Block("c", "a"); err != nil {
+			t.Fatal(err)
+		}
+		page, err := m.Mutual("a", "e", Page{Sort: SortName})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.Total != 1 {
+			t.Errorf("got %v; want %v", page.Total, 1)
+		}
+		if _, err := m.Mutual("a", "c", Page{}); err != ErrBlocked {
+			t.Errorf("got %v; want %v", err, ErrBlocked)
+		}
+	})
+}
@@ -0,0 +1,198 @@
+package friends
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+const (
+	// DefaultPlayedWindow is how long an account is kept in a recently played list
+	// if the configured window is zero
+	DefaultPlayedWindow = time.Hour * 24 * 30
+
+	// MaxPlayed limits how many accounts are kept in a recently played list. The
+	// oldest are dropped first.
+	MaxPlayed = 100
+
+	// MaxRoster limits how many accounts may be reported in one match roster
+	MaxRoster = 100
+)
+
+var (
+	// ErrBadRoster returned when a reported roster is missing its product,
+	// platform, or has fewer than two valid accounts
+	ErrBadRoster = errors.New("bad roster")
+
+	// ErrMaxRoster returned when a reported roster has too many accounts
+	ErrMaxRoster = errors.New("too many accounts in roster")
+
+	// ErrNotPlayed returned when an account is not in the recently played list
+	ErrNotPlayed = errors.New("account not recently played with")
+)
+
+// Roster is a match roster reported by a game server
+type Roster struct {
+	Product  string   `json:"product"`
+	Platform string   `json:"platform"`
+	BUIDs    []string `json:"buids"`
+}
+
+// Played is an account in a recently played list
+type Played struct {
+	BUID     string    `json:"buid"`
+	Name     string    `json:"username,omitempty"`
+	Product  string    `json:"product"`
+	Platform string    `json:"platform"`
+	Time     time.Time `json:"time"`
+}
+
+// AddRoster records every account of the roster as recently played with each
+// other account of the roster
+func (m *Manager) AddRoster(in Roster) error {
+	if in.Product == "" || in.Platform == "" {
+		return ErrBadRoster
+	}
+	if len(in.BUIDs) > MaxRoster {
+		return ErrMaxRoster
+	}
+	seen := make(map[string]bool, len(in.BUIDs))
+	ids := make([]string, 0, len(in.BUIDs))
+	for _, id := range in.BUIDs {
+		if !ValidBUID(id) {
+			return ErrBadBUID
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) < 2 {
+		return ErrBadRoster
+	}
+
+	b, _ := json.Marshal(&Played{Product: in.Product, Platform: in.Platform, Time: time.Now().UTC()})
+	row := string(b)
+	for _, id := range ids {
+		key := fmt.Sprintf(PaPlayed, id)
+		fields := make(map[string]interface{}, len(ids)-1)
+		for _, other := range ids {
+			if other != id {
+				fields[other] = row
+			}
+		}
+		if err := m.dba.HMSet(key, fields); err != nil {
+			return err
+		}
+		if err := m.dba.Expire(key, m.window()); err != nil {
+			return err
+		}
+		if _, err := m.recent(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetPlayed returns the recently played list of buid, most recent first, with
+// blocked accounts left out
+func (m *Manager) GetPlayed(buid string) ([]*Played, error) {
+	if buid == "" {
+		return nil, ErrBadBUID
+	}
+	list, err := m.recent(buid)
+	if err != nil {
+		return nil, err
+	}
+	blocked, err := m.blocked(buid)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*Played, 0, len(list))
+	for _, p := range list {
+		if !blocked[p.BUID] {
+			out = append(out, p)
+		}
+	}
+	if len(out) > 0 && m.identity != nil {
+		ids := make([]string, len(out))
+		for i, p := range out {
+			ids[i] = p.BUID
+		}
+		accounts, err := m.GetAccounts(ids...)
+		if err != nil {
+			log.Printf("manager: played: %v", err)
+		}
+		for _, p := range out {
+			if a, ok := accounts[p.BUID]; ok {
+				p.Name = a.Name
+			}
+		}
+	}
+	return out, nil
+}
+
+// RequestPlayed sends a friend request from buid to an account in its recently
+// played list
+func (m *Manager) RequestPlayed(buid, target string) (*Request, error) {
+	list, err := m.recent(buid)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range list {
+		if p.BUID == target {
+			return m.SendRequest(buid, target)
+		}
+	}
+	return nil, ErrNotPlayed
+}
+
+// recent returns the recently played list of buid, most recent first. Entries
+// older than the played window or past MaxPlayed are removed from the db.
+func (m *Manager) recent(buid string) ([]*Played, error) {
+	key := fmt.Sprintf(PaPlayed, buid)
+	rows, err := m.dba.HGetAll(key)
+	if err != nil {
+		return nil, err
+	}
+	cutoff := time.Now().Add(-m.window())
+	out, stale := make([]*Played, 0, len(rows)), []string{}
+	for id, row := range rows {
+		p := &Played{}
+		if err := json.Unmarshal([]byte(row), p); err != nil || p.Time.Before(cutoff) {
+			stale = append(stale, id)
+			continue
+		}
+		p.BUID = id
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].Time.Equal(out[j].Time) {
+			return out[i].Time.After(out[j].Time)
+		}
+		return out[i].BUID < out[j].BUID
+	})
+	if len(out) > MaxPlayed {
+		for _, p := range out[MaxPlayed:] {
+			stale = append(stale, p.BUID)
+		}
+		out = out[:MaxPlayed]
+	}
+	if len(stale) > 0 {
+		if err := m.dba.HDel(key, stale...); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// window returns how long accounts are kept in a recently played list
+func (m *Manager) window() time.Duration {
+	if m.playedWindow <= 0 {
+		return DefaultPlayedWindow
+	}
+	return m.playedWindow
+}
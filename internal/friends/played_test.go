@@ -0,0 +1,53 @@
+package friends
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestPlayed(t *testing.T) {
+	m, c := newTestManager()
+	m.playedWindow = time.Hour
+
+	t.Run("Roster", func(t *testing.T) {
+		for _, bad := range []Roster{
+			{Product: "fallout", BUIDs: []string{"a", "b"}},
+			{Product: "fallout", Platform: "pc", BUIDs: []string{"a", "a"}},
+		} {
+			if err := m.AddRoster(bad); err != ErrBadRoster {
+				t.Errorf("got %v; want %v", err, ErrBadRoster)
+			}
+		}
+		if err := m.AddRoster(Roster{Product: "fallout", Platform: "pc", BUIDs: []string{"a", "b", "c"}}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	/* ------------------------------------------------------------------------ */
+	t.Run("List", func(t *testing.T) {
+		// entries outside of the window are pruned on read
+		b, _ := json.Marshal(&Played{Product: "doom", Platform: "pc", Time: time.Now().Add(-time.Hour * 2)})
+		c.HSet(fmt.Sprintf(PaPlayed, "a"), "d", string(b))
+
+		out, err := m.GetPlayed("a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(out) != 2 || out[0].Product != "fallout" {
+			t.Errorf("got %+v; want %v", out, "b,c")
+		}
+		if _, ok := c.hash[fmt.Sprintf(PaPlayed, "a")]["d"]; ok {
+			t.Errorf("got %v; want %v", ok, false)
+		}
+	})
+	/* ------------------------------------------------------------------------ */
+	t.Run("Request", func(t *testing.T) {
+		if _, err := m.RequestPlayed("a", "b"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := m.RequestPlayed("a", "d"); err != ErrNotPlayed {
+			t.Errorf("got %v; want %v", err, ErrNotPlayed)
+		}
+	})
+}
@@ -0,0 +1,242 @@
+package friends
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/BethesdaNet/friends-go/internal/friends/status"
+)
+
+// Audiences a privacy setting may be limited to
+const (
+	AudienceEveryone         = "everyone"
+	AudienceFriendsOfFriends = "friends_of_friends"
+	AudienceFriends          = "friends"
+	AudienceNobody           = "nobody"
+)
+
+var (
+	// ErrBadPrivacy returned when a privacy setting has an unknown audience
+	ErrBadPrivacy = errors.New("bad privacy settings")
+
+	// ErrNotAccepting returned when the recipient does not accept friend requests
+	// from the sender
+	ErrNotAccepting = errors.New("account is not accepting friend requests")
+)
+
+// Privacy holds the privacy settings of an account. Requests is who may send
+// friend requests to the account and Presence is who may see its presence.
+// Searchable accounts may be found by other accounts in search.
+type Privacy struct {
+	Requests   string `json:"requests"`
+	Presence   string `json:"presence"`
+	Searchable bool   `json:"searchable"`
+}
+
+// DefaultPrivacy returns the settings of an account which never changed them
+func DefaultPrivacy() *Privacy {
+	return &Privacy{Requests: AudienceEveryone, Presence: AudienceEveryone, Searchable: true}
+}
+
+// Valid returns true if every setting has a known audience
+func (p *Privacy) Valid() bool {
+	switch p.Requests {
+	case AudienceEveryone, AudienceFriendsOfFriends, AudienceNobody:
+	default:
+		return false
+	}
+	switch p.Presence {
+	case AudienceEveryone, AudienceFriends, AudienceNobody:
+	default:
+		return false
+	}
+	return true
+}
+
+// allowsRequest returns true if a request from an account sharing mutual
+// friends with the account is allowed
+func (p *Privacy) allowsRequest(mutual int) bool {
+	switch p.Requests {
+	case AudienceNobody:
+		return false
+	case AudienceFriendsOfFriends:
+		return mutual > 0
+	}
+	return true
+}
+
+// GetPrivacy returns the privacy settings of buid
+func (m *Manager) GetPrivacy(buid string) (*Privacy, error) {
+	if buid == "" {
+		return nil, ErrBadBUID
+	}
+	out, err := m.privacy(buid)
+	if err != nil {
+		return nil, err
+	}
+	return out[buid], nil
+}
+
+// SetPrivacy stores the privacy settings of buid
+func (m *Manager) SetPrivacy(buid string, in *Privacy) error {
+	if buid == "" {
+		return ErrBadBUID
+	}
+	if !in.Valid() {
+		return ErrBadPrivacy
+	}
+	b, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	// settings never expire; expired settings would fall back to the defaults
+	if err := m.dba.SetBytesExpire(fmt.Sprintf(PaPrivacy, buid), b, 0); err != nil {
+		return err
+	}
+
+	// friends see the account as offline once presence is hidden
+	return m.schedulePresence(buid)
+}
+
+// Searchable returns the accounts of buids which may be found in search
+func (m *Manager) Searchable(buids ...string) ([]string, error) {
+	settings, err := m.privacy(buids...)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, 0, len(buids))
+	for _, id := range buids {
+		if p, ok := settings[id]; ok && p.Searchable {
+			out = append(out, id)
+		}
+	}
+	return out, nil
+}
+
+// privacy returns the privacy settings of buids in one MGet. Accounts without
+// stored settings get the defaults.
+func (m *Manager) privacy(buids ...string) (map[string]*Privacy, error) {
+	out := make(map[string]*Privacy, len(buids))
+	keys, order := make([]string, 0, len(buids)), make([]string, 0, len(buids))
+	for _, id := range buids {
+		if _, ok := out[id]; ok || id == "" {
+			continue
+		}
+		out[id] = DefaultPrivacy()
+		keys = append(keys, fmt.Sprintf(PaPrivacy, id))
+		order = append(order, id)
+	}
+	if len(keys) == 0 {
+		return out, nil
+	}
+	rows, err := m.dba.MGet(keys...)
+	if err != nil {
+		return nil, err
+	}
+	for i, row := range rows {
+		b, err := rowBytes(row)
+		if err != nil {
+			continue
+		}
+		p := DefaultPrivacy()
+		if err := json.Unmarshal(b, p); err == nil && p.Valid() {
+			out[order[i]] = p
+		}
+	}
+	return out, nil
+}
+
+// canRequest returns ErrNotAccepting unless the privacy settings of to allow a
+// friend request from
+func (m *Manager) canRequest(from, to string) error {
+	p, err := m.GetPrivacy(to)
+	if err != nil {
+		return err
+	}
+	mutual := 0
+	if p.Requests == AudienceFriendsOfFriends {
+		shared, err := m.dba.SInter(fmt.Sprintf(PaFriends, from), fmt.Sprintf(PaFriends, to))
+		if err != nil {
+			return err
+		}
+		mutual = len(shared)
+	}
+	if !p.allowsRequest(mutual) {
+		return ErrNotAccepting
+	}
+	return nil
+}
+
+// showsFriends returns which of buids let viewer see their friends. An account
+// shows its friends to its own friends, and to other accounts only while it is
+// searchable. Presence settings do not apply, so hiding presence neither hides
+// nor exposes the friend graph.
+func (m *Manager) showsFriends(viewer string, buids ...string) (map[string]bool, error) {
+	settings, err := m.privacy(buids...)
+	if err != nil {
+		return nil, err
+	}
+	var friends map[string]bool
+	out := make(map[string]bool, len(buids))
+	for _, id := range buids {
+		if settings[id].Searchable {
+			out[id] = true
+			continue
+		}
+		if friends == nil {
+			if friends, err = m.friendSet(viewer); err != nil {
+				return nil, err
+			}
+		}
+		out[id] = friends[id]
+	}
+	return out, nil
+}
+
+// conceal sets the statuses viewer may not see to offline. Friends of the
+// viewer are only looked up if an account limits its presence to friends.
+func (m *Manager) conceal(viewer string, statuses map[string]*Status) error {
+	ids := make([]string, 0, len(statuses))
+	for id := range statuses {
+		if id != viewer {
+			ids = append(ids, id)
+		}
+	}
+	settings, err := m.privacy(ids...)
+	if err != nil {
+		return err
+	}
+	var friends map[string]bool
+	for _, id := range ids {
+		switch settings[id].Presence {
+		case AudienceNobody:
+		case AudienceFriends:
+			if friends == nil {
+				if friends, err = m.friendSet(viewer); err != nil {
+					return err
+				}
+			}
+			if friends[id] {
+				continue
+			}
+		default:
+			continue
+		}
+		statuses[id] = statuses[id].Set(status.Offline)
+	}
+	return nil
+}
+
+// friendSet returns the friends of buid as a set
+func (m *Manager) friendSet(buid string) (map[string]bool, error) {
+	ids, err := m.dba.SMembers(fmt.Sprintf(PaFriends, buid))
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		out[id] = true
+	}
+	return out, nil
+}
@@ -0,0 +1,86 @@
+package friends
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/BethesdaNet/friends-go/internal/db/redis"
+	"github.com/BethesdaNet/friends-go/internal/friends/status"
+)
+
+func TestPrivacy(t *testing.T) {
+	m, c := newTestManager()
+	if err := m.addFriend("a", "b"); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Default", func(t *testing.T) {
+		p, err := m.GetPrivacy("c")
+		if err != nil || *p != *DefaultPrivacy() {
+			t.Errorf("got %+v,%v; want %+v", p, err, DefaultPrivacy())
+		}
+		if err := m.SetPrivacy("c", &Privacy{Requests: "friends", Presence: "friends"}); err != ErrBadPrivacy {
+			t.Errorf("got %v; want %v", err, ErrBadPrivacy)
+		}
+	})
+	t.Run("Persist", func(t *testing.T) {
+		key := fmt.Sprintf(PaPrivacy, "c")
+		c.Set(key, "{}", time.Minute)
+		if err := m.SetPrivacy("c", DefaultPrivacy()); err != nil {
+			t.Fatal(err)
+		}
+		if ttl, ok := c.ttl[key]; ok {
+			t.Errorf("got %v; want %v", ttl, "no expiry")
+		}
+	})
+	t.Run("Requests", func(t *testing.T) {
+		m.SetPrivacy("c", &Privacy{Requests: AudienceNobody, Presence: AudienceEveryone})
+		if _, err := m.SendRequest("a", "c"); err != ErrNotAccepting {
+			t.Errorf("got %v; want %v", err, ErrNotAccepting)
+		}
+		m.SetPrivacy("c", &Privacy{Requests: AudienceFriendsOfFriends, Presence: AudienceEveryone})
+		if _, err := m.SendRequest("a", "c"); err != ErrNotAccepting {
+			t.Errorf("got %v; want %v", err, ErrNotAccepting)
+		}
+		if err := m.addFriend("b", "c"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := m.SendRequest("a", "c"); err != nil {
+			t.Errorf("got %v; want %v", err, nil)
+		}
+	})
+	t.Run("Presence", func(t *testing.T) {
+		for _, id := range []string{"b", "d"} {
+			m.SetStatus(id, "", "", "", "", (&Status{}).Set(status.Online))
+		}
+		m.SetPrivacy("b", &Privacy{Requests: AudienceEveryone, Presence: AudienceFriends})
+		m.SetPrivacy("d", &Privacy{Requests: AudienceEveryone, Presence: AudienceNobody})
+		for _, tc := range []struct {
+			viewer, buid string
+			want         status.Kind
+		}{
+			{"a", "b", status.Online},
+			{"c", "b", status.Online},
+			{"e", "b", status.Offline},
+			{"a", "d", status.Offline},
+			{"d", "d", status.Online},
+		} {
+			got, err := m.ViewStatuses(tc.viewer, []string{tc.buid}, "", "")
+			if err != nil || got[tc.buid].Enum != tc.want {
+				t.Errorf("%s viewing %s: got %v,%v; want %v", tc.viewer, tc.buid, got[tc.buid].Enum, err, tc.want)
+			}
+		}
+	})
+	t.Run("Searchable", func(t *testing.T) {
+		// settings of different accounts are on different cluster slots
+		if a, e := redis.Slot(fmt.Sprintf(PaPrivacy, "a")), redis.Slot(fmt.Sprintf(PaPrivacy, "e")); a == e {
+			t.Fatalf("got %v,%v; want different slots", a, e)
+		}
+		m.SetPrivacy("e", &Privacy{Requests: AudienceEveryone, Presence: AudienceEveryone})
+		got, err := m.Searchable("a", "e")
+		if err != nil || len(got) != 1 || got[0] != "a" {
+			t.Errorf("got %v,%v; want %v", got, err, []string{"a"})
+		}
+	})
+}
@@ -0,0 +1,391 @@
+package friends
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/BethesdaNet/friends-go/internal/db/redis"
+	"github.com/BethesdaNet/friends-go/internal/provider"
+)
+
+// Notifications are queued in redis lists sharing the {friends.notes} hash tag so
+// elements can be moved between them atomically in a cluster. Workers move each
+// notification from the queue to their own working list while delivering it,
+// failed deliveries wait in the retry set until due, and notifications failing
+// permanently or too many times are kept in the dead letter list.
+const (
+	KyNoteQueue   = "{friends.notes}.queue"
+	KyNoteRetry   = "{friends.notes}.retry"
+	KyNoteDead    = "{friends.notes}.dead"
+	KyNoteWorkers = "{friends.notes}.workers"
+	PaNoteWorking = "{friends.notes}.working.%s"
+	PaNoteAlive   = "{friends.notes}.alive.%s"
+)
+
+const (
+	// DefaultNoteWorkers is how many notifications an instance delivers at once
+	DefaultNoteWorkers = 4
+
+	// MaxNoteQueue bounds the queue. Notifications sent while the queue is full
+	// are kept in the dead letter list rather than dropped.
+	MaxNoteQueue = 100000
+
+	// MaxNoteAttempts is how many times delivery is attempted before a
+	// notification is moved to the dead letter list
+	MaxNoteAttempts = 8
+
+	// NoteBackoff is the delay before the first retry, doubling for each attempt
+	// up to NoteBackoffMax
+	NoteBackoff    = time.Second
+	NoteBackoffMax = time.Minute * 5
+
+	// NotePoll is how long a worker blocks waiting for a notification and how
+	// often due retries are queued again
+	NotePoll = time.Second
+
+	// DefaultDrainTimeout is how long Close keeps delivering queued notifications
+	DefaultDrainTimeout = time.Second * 5
+)
+
+// Queued is a notification stored in the queue. Data is encoded when queued so
+// delivery does not depend on the type of the original data. A batch carries
+// the notifications it delivers instead of data.
+type Queued struct {
+	ID       string          `json:"id"`
+	Title    string          `json:"title"`
+	BUID     string          `json:"buid"`
+	Data     json.RawMessage `json:"data,omitempty"`
+	Batch    []*Queued       `json:"batch,omitempty"`
+	Announce bool            `json:"announce,omitempty"`
+	Attempts int             `json:"attempts"`
+	Error    string          `json:"error,omitempty"`
+	Time     time.Time       `json:"time"`
+}
+
+// queue sends notifications through the durable redis queue
+type queue struct {
+	owner   string
+	stop    chan struct{}
+	stopped sync.Once
+	wg      sync.WaitGroup
+}
+
+// SendNotification queues a notification to be delivered by the note provider.
+// Notifications other than announcements are batched per recipient first.
+// Notifications are only lost if the db is unreachable, which is logged.
+func (m *Manager) SendNotification(title, buid string, data interface{}, announce bool) {
+	if !EnableDaemon {
+		return
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("manager: notes: encode %q for %s: %v", title, buid, err)
+		return
+	}
+	q := &Queued{ID: noteID(), Title: title, BUID: buid, Data: b, Announce: announce, Time: time.Now().UTC()}
+	if announce {
+		err = m.enqueue(q)
+	} else {
+		err = m.batch(q, supersedes(q, data))
+	}
+	if err != nil {
+		log.Printf("manager: notes: dropped %q for %s: %v", title, buid, err)
+	}
+}
+
+// enqueue pushes q onto the queue, or the dead letter list if the queue is full
+func (m *Manager) enqueue(q *Queued) error {
+	key := KyNoteQueue
+	if n, err := m.dba.LLen(KyNoteQueue); err != nil {
+		return err
+	} else if n >= MaxNoteQueue {
+		log.Printf("manager: notes: queue full: dead lettered %q for %s", q.Title, q.BUID)
+		q.Error, key = "queue full", KyNoteDead
+	}
+	b, _ := json.Marshal(q)
+	_, err := m.dba.LPush(key, string(b))
+	return err
+}
+
+// work starts the notification workers of this instance
+func (m *Manager) work(n int) {
+	m.queue.owner = redis.NewOwner()
+	m.queue.stop = make(chan struct{})
+	if err := m.dba.SAdd(KyNoteWorkers, m.queue.owner); err != nil {
+		log.Printf("manager: notes: register: %v", err)
+	}
+	m.heartbeat()
+	for i := 0; i < n; i++ {
+		m.queue.wg.Add(1)
+		go m.worker()
+	}
+	m.queue.wg.Add(1)
+	go m.retrier()
+}
+
+// worker delivers queued notifications until the queue is stopped
+func (m *Manager) worker() {
+	defer m.queue.wg.Done()
+	working := fmt.Sprintf(PaNoteWorking, m.queue.owner)
+	for {
+		select {
+		case <-m.queue.stop:
+			return
+		default:
+		}
+		raw, err := m.dba.BRPopLPush(KyNoteQueue, working, NotePoll)
+		switch err {
+		case nil:
+			m.process(working, raw)
+		case redis.ErrBadKey:
+		default:
+			log.Printf("manager: notes: %v", err)
+			time.Sleep(NotePoll)
+		}
+	}
+}
+
+// process delivers raw and removes it from the working list once it has been
+// delivered, scheduled for retry, or dead lettered
+func (m *Manager) process(working, raw string) {
+	q := &Queued{}
+	if err := json.Unmarshal([]byte(raw), q); err != nil {
+		log.Printf("manager: notes: decode: %v", err)
+		m.deadLetter(q, raw, err)
+	} else if err := m.deliver(q); err != nil {
+		m.fail(q, err)
+	}
+	if err := m.dba.LRem(working, 1, raw); err != nil {
+		log.Printf("manager: notes: %v", err)
+	}
+}
+
+// fail schedules q to be retried with backoff, or dead letters it if the error
+// is permanent or q has been attempted too many times
+func (m *Manager) fail(q *Queued, err error) {
+	q.Attempts++
+	q.Error = err.Error()
+	b, _ := json.Marshal(q)
+	if !provider.Temporary(err) || q.Attempts >= MaxNoteAttempts {
+		m.deadLetter(q, string(b), err)
+		return
+	}
+	due := time.Now().Add(backoff(q.Attempts))
+	if err := m.dba.ZAdd(KyNoteRetry, float64(due.UnixNano()), string(b)); err != nil {
+		log.Printf("manager: notes: retry %s: %v", q.ID, err)
+	}
+}
+
+// deadLetter stores raw in the dead letter list
+func (m *Manager) deadLetter(q *Queued, raw string, err error) {
+	log.Printf("manager: notes: dead lettered %s %q for %s after %d attempts: %v", q.ID, q.Title, q.BUID, q.Attempts, err)
+	if _, err := m.dba.LPush(KyNoteDead, raw); err != nil {
+		log.Printf("manager: notes: dead letter %s: %v", q.ID, err)
+	}
+}
+
+// retrier queues due batches and retries and keeps the instance heartbeat alive
+func (m *Manager) retrier() {
+	defer m.queue.wg.Done()
+	hz := time.NewTicker(NotePoll)
+	defer hz.Stop()
+	for {
+		select {
+		case <-m.queue.stop:
+			return
+		case <-hz.C:
+			m.heartbeat()
+			m.flushBatches()
+			m.requeue()
+		}
+	}
+}
+
+// requeue moves due retries back onto the queue. Removal from the retry set
+// decides which instance requeues a retry when several find it due.
+func (m *Manager) requeue() {
+	due, err := m.dba.ZRangeByScore(KyNoteRetry, 0, float64(time.Now().UnixNano()), 100)
+	if err != nil {
+		log.Printf("manager: notes: retries: %v", err)
+		return
+	}
+	for _, raw := range due {
+		if n, err := m.dba.ZRem(KyNoteRetry, raw); err != nil || n == 0 {
+			continue
+		}
+		if _, err := m.dba.LPush(KyNoteQueue, raw); err != nil {
+			log.Printf("manager: notes: requeue: %v", err)
+		}
+	}
+}
+
+// heartbeat marks the workers of this instance alive
+func (m *Manager) heartbeat() {
+	key := fmt.Sprintf(PaNoteAlive, m.queue.owner)
+	if err := m.dba.SetBytesExpire(key, []byte("1"), NotePoll*5); err != nil {
+		log.Printf("manager: notes: heartbeat: %v", err)
+	}
+}
+
+// recoverNotes queues the working lists of instances which stopped without
+// draining them again. It is run by the leader while token leads.
+func (m *Manager) recoverNotes(token int64) {
+	owners, err := m.dba.SMembers(KyNoteWorkers)
+	if err != nil {
+		log.Printf("manager: notes: recover: %v", err)
+		return
+	}
+	for _, owner := range owners {
+		if owner == m.queue.owner {
+			continue
+		}
+		if ok, err := m.dba.Exists(fmt.Sprintf(PaNoteAlive, owner)); err != nil || ok {
+			continue
+		}
+		if !m.leads(token) {
+			return
+		}
+		n, err := m.release(owner)
+		if err != nil {
+			log.Printf("manager: notes: recover %s: %v", owner, err)
+			continue
+		}
+		if n > 0 {
+			log.Printf("manager: notes: recovered %d notifications from %s", n, owner)
+		}
+	}
+}
+
+// release moves the working list of owner back onto the queue and unregisters
+// owner
+func (m *Manager) release(owner string) (int, error) {
+	working, n := fmt.Sprintf(PaNoteWorking, owner), 0
+	for {
+		_, err := m.dba.RPopLPush(working, KyNoteQueue)
+		if err == redis.ErrBadKey {
+			break
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, m.dba.SRem(KyNoteWorkers, owner)
+}
+
+// drain stops the workers after queueing due batches and delivering what is
+// queued, giving up after timeout. Notifications still being delivered are left
+// on the queue for other instances.
+func (m *Manager) drain(timeout time.Duration) {
+	if m.queue.stop == nil {
+		return
+	}
+	deadline := time.Now().Add(timeout)
+	working := fmt.Sprintf(PaNoteWorking, m.queue.owner)
+	m.flushBatches()
+	for time.Now().Before(deadline) {
+		raw, err := m.dba.RPopLPush(KyNoteQueue, working)
+		if err != nil {
+			break
+		}
+		m.process(working, raw)
+	}
+	m.queue.stopped.Do(func() { close(m.queue.stop) })
+	m.queue.wg.Wait()
+	if _, err := m.release(m.queue.owner); err != nil {
+		log.Printf("manager: notes: release: %v", err)
+	}
+	if err := m.dba.Del(fmt.Sprintf(PaNoteAlive, m.queue.owner)); err != nil {
+		log.Printf("manager: notes: %v", err)
+	}
+}
+
+// GetDeadNotes returns up to limit dead lettered notifications, newest first
+func (m *Manager) GetDeadNotes(limit int) ([]*Queued, error) {
+	rows, err := m.dba.LRange(KyNoteDead, 0, int64(limit)-1)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*Queued, 0, len(rows))
+	for _, raw := range rows {
+		q := &Queued{}
+		if err := json.Unmarshal([]byte(raw), q); err != nil {
+			continue
+		}
+		out = append(out, q)
+	}
+	return out, nil
+}
+
+// ReplayDeadNotes queues up to count of the oldest dead lettered notifications
+// again with their attempts reset and returns how many were queued
+func (m *Manager) ReplayDeadNotes(count int) (int, error) {
+	n := 0
+	for ; n < count; n++ {
+		raw, err := m.dba.RPop(KyNoteDead)
+		if err == redis.ErrBadKey {
+			break
+		}
+		if err != nil {
+			return n, err
+		}
+		q := &Queued{}
+		if err := json.Unmarshal([]byte(raw), q); err != nil {
+			log.Printf("manager: notes: replay: discarded undecodable notification: %v", err)
+			continue
+		}
+		q.Attempts, q.Error = 0, ""
+		b, _ := json.Marshal(q)
+		if _, err := m.dba.LPush(KyNoteQueue, string(b)); err != nil {
+			// put it back so the notification is not lost
+			m.dba.LPush(KyNoteDead, raw)
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// deliver sends q through the note provider
+func (m *Manager) deliver(q *Queued) error {
+	if m.note == nil {
+		return nil
+	}
+	if len(q.Batch) > 0 {
+		items := make([]provider.NoteItem, len(q.Batch))
+		for i, n := range q.Batch {
+			items[i] = provider.NoteItem{Type: n.Title, Payload: n.Data}
+		}
+		// items delivered before a failure are dropped so a retry does not send
+		// them again
+		n, err := m.note.Batch(q.BUID, items, nil)
+		if err != nil && n > 0 {
+			q.Batch = q.Batch[n:]
+		}
+		return err
+	}
+	if q.Announce {
+		return m.note.Announcement(q.Title, string(q.Data), q.BUID, nil)
+	}
+	return m.note.Notification(q.Title, string(q.Data), q.BUID, nil)
+}
+
+// backoff returns the delay before retry attempt n
+func backoff(n int) time.Duration {
+	d := time.Duration(float64(NoteBackoff) * math.Pow(2, float64(n-1)))
+	if d <= 0 || d > NoteBackoffMax {
+		return NoteBackoffMax
+	}
+	return d
+}
+
+func noteID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,102 @@
+package friends
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/BethesdaNet/friends-go/internal/client"
+)
+
+func TestQueue(t *testing.T) {
+	m, c := newTestManager()
+	m.queue.owner, m.queue.stop = "test", make(chan struct{})
+	working := fmt.Sprintf(PaNoteWorking, m.queue.owner)
+
+	pop := func() *Queued {
+		raw, err := m.dba.RPopLPush(KyNoteQueue, working)
+		if err != nil {
+			t.Fatalf("got %v; want %v", err, nil)
+		}
+		q := &Queued{}
+		json.Unmarshal([]byte(raw), q)
+		m.dba.LRem(working, 1, raw)
+		return q
+	}
+
+	t.Run("Retry", func(t *testing.T) {
+		m.SendNotification(NoteFriendRequestExpired, "a", &Request{From: "a", To: "b"}, false)
+		flushNotes(m, c)
+		q := pop()
+		m.fail(q, client.PlatformError{Code: 503})
+		if n := len(c.zsets[KyNoteRetry]); n != 1 {
+			t.Fatalf("got %v; want %v", n, 1)
+		}
+
+		// not due until the backoff has passed
+		m.requeue()
+		if n, _ := m.dba.LLen(KyNoteQueue); n != 0 {
+			t.Errorf("got %v; want %v", n, 0)
+		}
+		for k := range c.zsets[KyNoteRetry] {
+			c.zsets[KyNoteRetry][k] = 0
+		}
+		m.requeue()
+		if q := pop(); q.Attempts != 1 || q.Error == "" || q.BUID != "a" {
+			t.Errorf("got %+v; want %v attempts", q, 1)
+		}
+	})
+	t.Run("DeadLetter", func(t *testing.T) {
+		m.SendNotification(NoteFriendRequestExpired, "a", nil, false)
+		flushNotes(m, c)
+		m.fail(pop(), client.PlatformError{Code: 400})
+		q := &Queued{Title: "x", BUID: "b", Attempts: MaxNoteAttempts - 1}
+		m.fail(q, client.PlatformError{Code: 503})
+
+		dead, err := m.GetDeadNotes(10)
+		if err != nil || len(dead) != 2 {
+			t.Fatalf("got %v,%v; want %v", len(dead), err, 2)
+		}
+		if dead[0].BUID != "b" || dead[0].Attempts != MaxNoteAttempts {
+			t.Errorf("got %+v; want %v", dead[0], "b")
+		}
+	})
+	t.Run("Replay", func(t *testing.T) {
+		n, err := m.ReplayDeadNotes(5)
+		if err != nil || n != 2 {
+			t.Fatalf("got %v,%v; want %v", n, err, 2)
+		}
+		if q := pop(); q.BUID != "a" || q.Attempts != 0 || q.Error != "" {
+			t.Errorf("got %+v; want %v", q, "a")
+		}
+		if q := pop(); q.BUID != "b" {
+			t.Errorf("got %+v; want %v", q, "b")
+		}
+		if dead, _ := m.GetDeadNotes(10); len(dead) != 0 {
+			t.Errorf("got %v; want %v", len(dead), 0)
+		}
+	})
+	t.Run("Recover", func(t *testing.T) {
+		c.SAdd(KyNoteWorkers, "gone")
+		c.LPush(fmt.Sprintf(PaNoteWorking, "gone"), `{"id":"1","buid":"c"}`)
+		m.recoverNotes(m.token())
+		if q := pop(); q.BUID != "c" {
+			t.Errorf("got %+v; want %v", q, "c")
+		}
+		if ok, _ := m.dba.SIsMember(KyNoteWorkers, "gone"); ok {
+			t.Errorf("got %v; want %v", ok, false)
+		}
+	})
+	t.Run("Drain", func(t *testing.T) {
+		m.SendNotification(NoteFriendRequestExpired, "a", nil, false)
+		flushNotes(m, c)
+		m.drain(time.Second)
+		if n, _ := m.dba.LLen(KyNoteQueue); n != 0 {
+			t.Errorf("got %v; want %v", n, 0)
+		}
+		if n, _ := m.dba.LLen(working); n != 0 {
+			t.Errorf("got %v; want %v", n, 0)
+		}
+	})
+}
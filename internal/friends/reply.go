@@ -0,0 +1,38 @@
+package friends
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/BethesdaNet/friends-go/internal/platform"
+)
+
+// reply writes data to the client wrapped in the platform envelope
+func (f *Friends) reply(w http.ResponseWriter, status int, data interface{}) {
+	if err := platform.Write(w, status, data); err != nil {
+		f.Log.Printf("reply: write err: %v", err)
+	}
+}
+
+// fail writes err to the client using the status mapped in errStatus
+func (f *Friends) fail(w http.ResponseWriter, err error) {
+	status, ok := errStatus[err]
+	if !ok {
+		f.Log.Printf("fail: internal err: %v", err)
+		status = http.StatusInternalServerError
+		f.reply(w, status, http.StatusText(status))
+		return
+	}
+	f.reply(w, status, err)
+}
+
+// decode reads the json request body into v
+func decode(r *http.Request, v interface{}) error {
+	if r.Body == nil {
+		return ErrBadBody
+	}
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return ErrBadBody
+	}
+	return nil
+}
@@ -0,0 +1,308 @@
+package friends
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/BethesdaNet/friends-go/internal/db/redis"
+)
+
+const (
+	// DefaultRequestTTL is how long a friend request stays pending if the
+	// configured ttl is zero
+	DefaultRequestTTL = time.Hour * 24 * 30
+
+	// MaxOutgoingRequests limits how many friend requests an account may have
+	// pending at once to prevent request spam
+	MaxOutgoingRequests = 100
+
+	// NoteFriendRequest notification title sent to the recipient of a request
+	NoteFriendRequest = "Friend Request"
+
+	// NoteFriendAccepted notification title sent to the sender once accepted
+	NoteFriendAccepted = "Friend Request Accepted"
+)
+
+// Request represents a pending friend request between two accounts. Requests
+// are stored under the recipients key and indexed for both accounts.
+type Request struct {
+	From string    `json:"from"`
+	To   string    `json:"to"`
+	Time time.Time `json:"time"`
+}
+
+// Requests groups pending friend requests by direction for an account
+type Requests struct {
+	Incoming []*Request `json:"incoming"`
+	Outgoing []*Request `json:"outgoing"`
+}
+
+// SendRequest creates a pending friend request from one account to another and
+// notifies the recipient.
+func (m *Manager) SendRequest(from, to string) (*Request, error) {
+	switch {
+	case from == "" || to == "":
+		return nil, ErrBadBUID
+	case from == to:
+		return nil, ErrSelfRequest
+	}
+	if ok, err := m.IsBlocked(from, to); err != nil {
+		return nil, err
+	} else if ok {
+		return nil, ErrBlocked
+	}
+	if ok, err := m.IsFriend(from, to); err != nil {
+		return nil, err
+	} else if ok {
+		return nil, ErrAlreadyFriends
+	}
+	if err := m.canRequest(from, to); err != nil {
+		return nil, err
+	}
+	if ok, err := m.dba.Exists(fmt.Sprintf(PaRequest, to, from)); err != nil {
+		return nil, err
+	} else if ok {
+		return nil, ErrRequestExists
+	}
+	if ok, err := m.dba.Exists(fmt.Sprintf(PaRequest, from, to)); err != nil {
+		return nil, err
+	} else if ok {
+		return nil, ErrRequestPending
+	}
+	if n, err := m.dba.SCard(fmt.Sprintf(PaRequestsOut, from)); err != nil {
+		return nil, err
+	} else if n >= MaxOutgoingRequests {
+		return nil, ErrMaxRequests
+	}
+
+	if err := m.claimRequest(from, to); err != nil {
+		return nil, err
+	}
+
+	req := &Request{From: from, To: to, Time: time.Now().UTC()}
+	if err := m.putRequest(req, m.requestTTL); err != nil {
+		return nil, err
+	}
+
+	m.SendNotification(NoteFriendRequest, to, req, false)
+
+	return req, nil
+}
+
+// AcceptRequest accepts the pending request sent to an account by from. Both
+// accounts are added to each others friend list.
+func (m *Manager) AcceptRequest(to, from string) error {
+	req, err := m.GetRequest(to, from)
+	if err != nil {
+		return err
+	}
+	if err := m.addFriend(req.To, req.From); err != nil {
+		return err
+	}
+	if err := m.delRequest(req.To, req.From); err != nil {
+		return err
+	}
+
+	m.SendNotification(NoteFriendAccepted, req.From, req, false)
+
+	return nil
+}
+
+// DeclineRequest removes the pending request sent to an account by from. The
+// sender is not notified.
+func (m *Manager) DeclineRequest(to, from string) error {
+	if _, err := m.GetRequest(to, from); err != nil {
+		return err
+	}
+	return m.delRequest(to, from)
+}
+
+// CancelRequest removes the pending request sent by an account to recipient to
+func (m *Manager) CancelRequest(from, to string) error {
+	if _, err := m.GetRequest(to, from); err != nil {
+		return err
+	}
+	return m.delRequest(to, from)
+}
+
+// GetRequest returns the pending request sent to an account by from
+func (m *Manager) GetRequest(to, from string) (*Request, error) {
+	if to == "" || from == "" {
+		return nil, ErrBadBUID
+	}
+	raw, err := m.dba.GetBytes(fmt.Sprintf(PaRequest, to, from))
+	if err != nil {
+		switch err {
+		case redis.ErrBadKey:
+			return nil, ErrRequestNotFound
+		default:
+			return nil, err
+		}
+	}
+	req := &Request{}
+	if err := gob.NewDecoder(bytes.NewBuffer(raw)).Decode(req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// GetRequests returns all incoming and outgoing pending requests of buid sorted
+// by the time they were sent (newest first).
+func (m *Manager) GetRequests(buid string) (*Requests, error) {
+	if buid == "" {
+		return nil, ErrBadBUID
+	}
+	in, err := m.dba.SMembers(fmt.Sprintf(PaRequestsIn, buid))
+	if err != nil {
+		return nil, err
+	}
+	out, err := m.dba.SMembers(fmt.Sprintf(PaRequestsOut, buid))
+	if err != nil {
+		return nil, err
+	}
+	data := &Requests{
+		Incoming: make([]*Request, 0, len(in)),
+		Outgoing: make([]*Request, 0, len(out)),
+	}
+
+	// requests missing from the db expired without their keyspace notification
+	// being handled and are removed from the indexes here instead
+	for _, from := range in {
+		req, err := m.GetRequest(buid, from)
+		if err != nil {
+			if err == ErrRequestNotFound {
+				err = m.expireRequest(buid, from)
+			}
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		data.Incoming = append(data.Incoming, req)
+	}
+	for _, to := range out {
+		req, err := m.GetRequest(to, buid)
+		if err != nil {
+			if err == ErrRequestNotFound {
+				err = m.expireRequest(to, buid)
+			}
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		data.Outgoing = append(data.Outgoing, req)
+	}
+	for _, set := range [][]*Request{data.Incoming, data.Outgoing} {
+		sort.Slice(set, func(i, j int) bool { return set[i].Time.After(set[j].Time) })
+	}
+	return data, nil
+}
+
+// claimRequest indexes the request from one account to another unless a request
+// between them is already indexed in either direction. Both directions are
+// indexed in the slot of the lesser buid, so two accounts requesting each other
+// at once cannot both claim a request.
+func (m *Manager) claimRequest(from, to string) error {
+	key, reverse, member := fmt.Sprintf(PaRequestsOut, from), fmt.Sprintf(PaRequestsIn, from), to
+	if to < from {
+		key, reverse, member = fmt.Sprintf(PaRequestsIn, to), fmt.Sprintf(PaRequestsOut, to), from
+	}
+	held, err := m.dba.SAddUnless(key, member, reverse)
+	switch {
+	case err != nil:
+		return err
+	case held == key:
+		return ErrRequestExists
+	case held == reverse:
+		return ErrRequestPending
+	}
+	return nil
+}
+
+// putRequest stores req expiring after ttl and indexes it for both accounts
+func (m *Manager) putRequest(req *Request, ttl time.Duration) error {
+	buf := &bytes.Buffer{}
+	if err := gob.NewEncoder(buf).Encode(req); err != nil {
+		return err
+	}
+	if err := m.dba.SetBytesExpire(fmt.Sprintf(PaRequest, req.To, req.From), buf.Bytes(), ttl); err != nil {
+		return err
+	}
+	if err := m.dba.SAdd(fmt.Sprintf(PaRequestsIn, req.To), req.From); err != nil {
+		return err
+	}
+	return m.dba.SAdd(fmt.Sprintf(PaRequestsOut, req.From), req.To)
+}
+
+// delRequest removes the request record and both index entries
+func (m *Manager) delRequest(to, from string) error {
+	if err := m.dba.Del(fmt.Sprintf(PaRequest, to, from)); err != nil {
+		return err
+	}
+	if err := m.dba.SRem(fmt.Sprintf(PaRequestsIn, to), from); err != nil {
+		return err
+	}
+	return m.dba.SRem(fmt.Sprintf(PaRequestsOut, from), to)
+}
+
+/* -------------------------------------------------------------------------- */
+
+// IsFriend returns true if a and b are friends
+func (m *Manager) IsFriend(a, b string) (bool, error) {
+	return m.dba.SIsMember(fmt.Sprintf(PaFriends, a), b)
+}
+
+// RemoveFriend removes the friendship between a and b from both accounts
+func (m *Manager) RemoveFriend(a, b string) error {
+	if a == "" || b == "" {
+		return ErrBadBUID
+	}
+	ok, err := m.IsFriend(a, b)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotFriends
+	}
+	for _, edge := range [][2]string{{a, b}, {b, a}} {
+		if err := m.dba.SRem(fmt.Sprintf(PaFriends, edge[0]), edge[1]); err != nil {
+			return err
+		}
+		if err := m.dba.HDel(fmt.Sprintf(PaFriendsSince, edge[0]), edge[1]); err != nil {
+			return err
+		}
+		if err := m.dba.SRem(fmt.Sprintf(PaMuted, edge[0]), edge[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addFriend stores the friendship edge on both accounts
+func (m *Manager) addFriend(a, b string) error {
+	if err := m.link(a, b, time.Now()); err != nil {
+		return err
+	}
+
+	// the friend graph of both accounts changed so their suggestions are stale
+	return m.queueSuggestions(a, b)
+}
+
+// link stores the friendship edge on both accounts as friends since
+func (m *Manager) link(a, b string, since time.Time) error {
+	ts := strconv.FormatInt(since.Unix(), 10)
+	for _, edge := range [][2]string{{a, b}, {b, a}} {
+		if err := m.dba.SAdd(fmt.Sprintf(PaFriends, edge[0]), edge[1]); err != nil {
+			return err
+		}
+		if err := m.dba.HSet(fmt.Sprintf(PaFriendsSince, edge[0]), edge[1], ts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
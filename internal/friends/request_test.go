@@ -0,0 +1,92 @@
+package friends
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRequests(t *testing.T) {
+	m, _ := newTestManager()
+	if err := m.addFriend("a", "f"); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Send", func(t *testing.T) {
+		for _, tc := range []struct {
+			from, to string
+			want     error
+		}{
+			{"a", "", ErrBadBUID},
+			{"a", "a", ErrSelfRequest},
+			{"a", "f", ErrAlreadyFriends},
+			{"a", "b", nil},
+			{"a", "b", ErrRequestExists},
+			{"b", "a", ErrRequestPending},
+			{"c", "a", nil},
+			{"d", "a", nil},
+		} {
+			if _, err := m.SendRequest(tc.from, tc.to); err != tc.want {
+				t.Errorf("%s to %s: got %v; want %v", tc.from, tc.to, err, tc.want)
+			}
+		}
+	})
+	/* ------------------------------------------------------------------------ */
+	t.Run("Crossed", func(t *testing.T) {
+		// each account claims its request before the other stored its record
+		for _, tc := range [][2]string{{"x", "y"}, {"z", "w"}} {
+			if err := m.claimRequest(tc[0], tc[1]); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := m.SendRequest(tc[1], tc[0]); err != ErrRequestPending {
+				t.Errorf("%s to %s: got %v; want %v", tc[1], tc[0], err, ErrRequestPending)
+			}
+		}
+	})
+	/* ------------------------------------------------------------------------ */
+	t.Run("Max", func(t *testing.T) {
+		for i := 0; i < MaxOutgoingRequests; i++ {
+			if _, err := m.SendRequest("m", fmt.Sprintf("r%d", i)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := m.SendRequest("m", "a"); err != ErrMaxRequests {
+			t.Errorf("got %v; want %v", err, ErrMaxRequests)
+		}
+	})
+	/* ------------------------------------------------------------------------ */
+	t.Run("Answer", func(t *testing.T) {
+		for _, tc := range []struct {
+			name string
+			do   func() error
+			want error
+		}{
+			{"accept missing", func() error { return m.AcceptRequest("a", "x") }, ErrRequestNotFound},
+			{"decline missing", func() error { return m.DeclineRequest("a", "x") }, ErrRequestNotFound},
+			{"cancel missing", func() error { return m.CancelRequest("a", "x") }, ErrRequestNotFound},
+			{"accept sent", func() error { return m.AcceptRequest("a", "b") }, ErrRequestNotFound},
+			{"accept", func() error { return m.AcceptRequest("a", "c") }, nil},
+			{"accept twice", func() error { return m.AcceptRequest("a", "c") }, ErrRequestNotFound},
+			{"decline", func() error { return m.DeclineRequest("a", "d") }, nil},
+			{"cancel", func() error { return m.CancelRequest("a", "b") }, nil},
+		} {
+			if err := tc.do(); err != tc.want {
+				t.Errorf("%s: got %v; want %v", tc.name, err, tc.want)
+			}
+		}
+		if ok, _ := m.IsFriend("c", "a"); !ok {
+			t.Errorf("got %v; want %v", ok, true)
+		}
+		for _, id := range []string{"b", "d"} {
+			if ok, _ := m.IsFriend("a", id); ok {
+				t.Errorf("%s: got %v; want %v", id, ok, false)
+			}
+		}
+		reqs, err := m.GetRequests("a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(reqs.Incoming)+len(reqs.Outgoing) != 0 {
+			t.Errorf("got %+v; want none", reqs)
+		}
+	})
+}
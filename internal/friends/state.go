@@ -1,5 +1,16 @@
 package friends
 
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/BethesdaNet/friends-go/internal/friends/status"
+	"github.com/BethesdaNet/friends-go/internal/platform"
+)
+
 // State struct contains attributes used during client requests. Middleware will
 // add platform specific data populated from the sidecar
 type State struct {
@@ -37,3 +48,137 @@ type State struct {
 	// Finger used for client session fingerprint
 	Finger string `json:"fp"`
 }
+
+var (
+	// ErrUnauthenticated returned when the request is missing the platform headers
+	// identifying the caller
+	ErrUnauthenticated = errors.New("unauthenticated")
+
+	// ErrBadHeader returned when a platform header contains a malformed value
+	ErrBadHeader = errors.New("bad platform header")
+)
+
+// MaxHeaderLength limits the length of platform header values kept in State
+const MaxHeaderLength = 4096
+
+// validID matches buids and other platform identifiers
+var validID = regexp.MustCompile(`^[0-9A-Za-z_\-]{1,64}$`)
+
+// ValidBUID returns true if buid is a well formed bnet account id
+func ValidBUID(buid string) bool {
+	return validID.MatchString(buid)
+}
+
+// reservedProducts are the first segments of the records stored under the
+// {buid} hash tag. Status keys are {buid}.<product>.<platform> so a status of
+// these products would overwrite the friend graph or status records.
+var reservedProducts = segments(
+	SxAnnounced, SxBlocks, SxBlockedBy, SxDismissed, SxFriends, SxFriendsSince,
+	SxMuted, SxPlayed, SxPresencePending, SxPrivacy, SxProducts, SxRequest,
+	SxRequestsIn, SxRequestsOut, SxSuggestions, ".notes", ".identity", ".hashes",
+	status.SxGlobal, status.SxPlayer, status.SxGameMain, status.SxGameExt,
+	status.SxCustom, status.SxJoinable, status.SxConnection, status.SxDoNotDisturb,
+	status.SxLastActivity, status.SxOffline, status.SxIdle, status.SxKeys,
+	status.SxProcesses,
+)
+
+// ValidProduct returns true if product may be part of a status key
+func ValidProduct(product string) bool {
+	return validID.MatchString(product) && !reservedProducts[product]
+}
+
+// ValidPlatform returns true if platform may be part of a status key
+func ValidPlatform(platform string) bool {
+	return validID.MatchString(platform)
+}
+
+// segments returns the first segment of each key suffix as a set
+func segments(suffix ...string) map[string]bool {
+	out := make(map[string]bool, len(suffix))
+	for _, sx := range suffix {
+		sx = strings.TrimPrefix(sx, status.DeKey)
+		if i := strings.Index(sx, status.DeKey); i >= 0 {
+			sx = sx[:i]
+		}
+		out[sx] = true
+	}
+	return out
+}
+
+// NewState returns the request state populated from the platform headers set by
+// the sidecar on inbound requests
+func NewState(r *http.Request) State {
+	s := State{
+		BUID:     r.Header.Get(platform.HeaderBUID),
+		Key:      r.Header.Get(platform.HeaderKey),
+		Session:  r.Header.Get(platform.HeaderSession),
+		Scope:    r.Header.Get(platform.HeaderScope),
+		Role:     r.Header.Get(platform.HeaderService),
+		Platform: r.Header.Get(platform.HeaderPlatform),
+		Product:  r.Header.Get(platform.HeaderProduct),
+		Finger:   r.Header.Get(platform.HeaderFinger),
+	}
+	if s.Platform == "" {
+		s.Platform = r.Header.Get(platform.HeaderPlatformBNET)
+	}
+	if s.Scope == "" {
+		s.Scope = platform.ScopeBasic
+	}
+	s.Language, s.Country = locale(r.Header.Get(platform.HeaderLanguage))
+	return s
+}
+
+// Check validates the state populated by NewState. Missing caller identity is
+// reported as ErrUnauthenticated while malformed values return ErrBadHeader.
+func (s State) Check() error {
+	if s.BUID == "" || s.Session == "" {
+		return ErrUnauthenticated
+	}
+	if !ValidBUID(s.BUID) {
+		return ErrBadHeader
+	}
+	if s.Product != "" && !ValidProduct(s.Product) {
+		return ErrBadHeader
+	}
+	if s.Platform != "" && !ValidPlatform(s.Platform) {
+		return ErrBadHeader
+	}
+	for _, v := range []string{s.Key, s.Session, s.Scope, s.Role, s.Platform, s.Finger} {
+		if len(v) > MaxHeaderLength {
+			return ErrBadHeader
+		}
+	}
+	return nil
+}
+
+// locale returns the language and country of the first Accept-Language entry
+func locale(v string) (string, string) {
+	if i := strings.IndexAny(v, ",;"); i >= 0 {
+		v = v[:i]
+	}
+	v = strings.TrimSpace(v)
+	if v == "" || v == "*" || len(v) > 16 {
+		return "", ""
+	}
+	parts := strings.SplitN(strings.Replace(v, "_", "-", 1), "-", 2)
+	lang := strings.ToLower(parts[0])
+	if len(parts) == 1 {
+		return lang, ""
+	}
+	return lang, strings.ToUpper(parts[1])
+}
+
+type ctxKey int
+
+const stateKey ctxKey = iota
+
+// WithState returns a copy of ctx carrying the request state
+func WithState(ctx context.Context, s State) context.Context {
+	return context.WithValue(ctx, stateKey, s)
+}
+
+// GetState returns the request state attached by the Authenticate middleware
+func GetState(r *http.Request) State {
+	s, _ := r.Context().Value(stateKey).(State)
+	return s
+}
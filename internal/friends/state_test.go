@@ -0,0 +1,84 @@
+package friends
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/BethesdaNet/friends-go/internal/platform"
+)
+
+func TestState(t *testing.T) {
+	t.Run("BUID", func(t *testing.T) {
+		for _, tc := range []struct {
+			buid string
+			want bool
+		}{
+			{"abc-123_XYZ", true},
+			{strings.Repeat("a", 64), true},
+			{"", false},
+			{strings.Repeat("a", 65), false},
+			{"a.b", false},
+			{"a}", false},
+			{"a b", false},
+		} {
+			if got := ValidBUID(tc.buid); got != tc.want {
+				t.Errorf("%q: got %v; want %v", tc.buid, got, tc.want)
+			}
+		}
+	})
+	/* ------------------------------------------------------------------------ */
+	t.Run("Check", func(t *testing.T) {
+		for _, tc := range []struct {
+			name string
+			s    State
+			want error
+		}{
+			{"ok", State{BUID: "a", Session: "s", Product: "fallout", Platform: "pc"}, nil},
+			{"no buid", State{Session: "s"}, ErrUnauthenticated},
+			{"no session", State{BUID: "a"}, ErrUnauthenticated},
+			{"bad buid", State{BUID: "a.friends", Session: "s"}, ErrBadHeader},
+			{"bad product", State{BUID: "a", Session: "s", Product: "fall out"}, ErrBadHeader},
+			{"long platform", State{BUID: "a", Session: "s", Platform: strings.Repeat("p", MaxHeaderLength+1)}, ErrBadHeader},
+			{"long key", State{BUID: "a", Session: "s", Key: strings.Repeat("k", MaxHeaderLength+1)}, ErrBadHeader},
+		} {
+			if err := tc.s.Check(); err != tc.want {
+				t.Errorf("%s: got %v; want %v", tc.name, err, tc.want)
+			}
+		}
+	})
+	/* ------------------------------------------------------------------------ */
+	t.Run("StatusKey", func(t *testing.T) {
+		for _, tc := range []struct {
+			product, platform string
+			want              error
+		}{
+			{"fallout", "pc", nil},
+			{"friends", "since", ErrBadHeader},
+			{"requests", "out", ErrBadHeader},
+			{"notes", "batch", ErrBadHeader},
+			{"global_status", "last_activity_timestamp", ErrBadHeader},
+			{"fallout", "pc.friends", ErrBadHeader},
+			{"fallout", "pc}", ErrBadHeader},
+		} {
+			s := State{BUID: "a", Session: "s", Product: tc.product, Platform: tc.platform}
+			if err := s.Check(); err != tc.want {
+				t.Errorf("%s.%s: got %v; want %v", tc.product, tc.platform, err, tc.want)
+			}
+		}
+	})
+	/* ------------------------------------------------------------------------ */
+	t.Run("New", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(platform.HeaderBUID, "a")
+		r.Header.Set(platform.HeaderSession, "s")
+		r.Header.Set(platform.HeaderPlatformBNET, "pc")
+		r.Header.Set(platform.HeaderLanguage, "en_us;q=0.9, fr")
+		s := NewState(r)
+		want := State{BUID: "a", Session: "s", Scope: platform.ScopeBasic, Platform: "pc", Language: "en", Country: "US"}
+		if s != want {
+			t.Errorf("got %+v; want %+v", s, want)
+		}
+	})
+}
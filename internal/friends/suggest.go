@@ -0,0 +1,338 @@
+package friends
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/BethesdaNet/friends-go/internal/db/redis"
+)
+
+const (
+	// MaxSuggestions limits how many suggestions are stored per account
+	MaxSuggestions = 50
+
+	// MaxSuggestionCandidates limits how many candidates are scored per account
+	MaxSuggestionCandidates = 200
+
+	// SuggestBatchSize is how many queued accounts a single daemon tick computes.
+	// Accounts are popped from the queue so every instance takes its own share.
+	SuggestBatchSize = 25
+
+	// DefaultSuggestionTTL is how long precomputed suggestions are served before
+	// the account is queued to be computed again
+	DefaultSuggestionTTL = time.Hour * 24
+
+	// SuggestionRefresh is how long after being stored suggestions are computed
+	// again. It is below DefaultSuggestionTTL so they are replaced before they
+	// expire.
+	SuggestionRefresh = time.Hour * 20
+
+	// CoPlayWindow is how far back a co-play session counts towards a suggestion
+	CoPlayWindow = time.Hour * 24 * 14
+)
+
+// Suggestion weights added to the score of a candidate for each signal
+const (
+	WeightMutual   = 10
+	WeightCoPlay   = 25
+	WeightProduct  = 5
+	WeightCountry  = 3
+	WeightLanguage = 2
+)
+
+// Suggestion reasons returned with each suggestion
+const (
+	ReasonMutual   = "mutual_friends"
+	ReasonCoPlay   = "played_with"
+	ReasonProduct  = "product"
+	ReasonCountry  = "country"
+	ReasonLanguage = "language"
+)
+
+// Suggestion is an account suggested as a new friend
+type Suggestion struct {
+	BUID    string   `json:"buid"`
+	Name    string   `json:"username"`
+	Score   int      `json:"score"`
+	Mutual  int      `json:"mutual"`
+	Reasons []string `json:"reasons"`
+}
+
+// GetSuggestions returns the precomputed suggestions of buid. Suggestions made
+// stale by friendships, requests, blocks, or privacy settings since they were
+// computed are left out. If nothing is stored the account is queued and an
+// empty list returned.
+func (m *Manager) GetSuggestions(buid string) ([]*Suggestion, error) {
+	if buid == "" {
+		return nil, ErrBadBUID
+	}
+	raw, err := m.dba.GetBytes(fmt.Sprintf(PaSuggestions, buid))
+	switch err {
+	case nil:
+	case redis.ErrBadKey:
+		return []*Suggestion{}, m.queueSuggestions(buid)
+	default:
+		return nil, err
+	}
+	stored := []*Suggestion{}
+	if err := gob.NewDecoder(bytes.NewBuffer(raw)).Decode(&stored); err != nil {
+		return []*Suggestion{}, m.queueSuggestions(buid)
+	}
+	skip, err := m.excluded(buid)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*Suggestion, 0, len(stored))
+	for _, s := range stored {
+		if !skip[s.BUID] {
+			out = append(out, s)
+		}
+	}
+	return m.requestable(out)
+}
+
+// DismissSuggestion stops target from being suggested to buid
+func (m *Manager) DismissSuggestion(buid, target string) error {
+	if buid == "" || target == "" {
+		return ErrBadBUID
+	}
+	return m.dba.SAdd(fmt.Sprintf(PaDismissed, buid), target)
+}
+
+// queueSuggestions queues accounts to have their suggestions computed by the
+// daemon
+func (m *Manager) queueSuggestions(buid ...string) error {
+	return m.dba.SAdd(KySuggestQueue, buid...)
+}
+
+// precompute pops a batch of queued accounts, topped up with accounts due a
+// refresh, and stores their suggestions while token leads. Accounts which fail
+// are queued again.
+func (m *Manager) precompute(token int64) {
+	if !m.leads(token) {
+		return
+	}
+	ids, err := m.dba.SPopN(KySuggestQueue, SuggestBatchSize)
+	if err != nil {
+		log.Printf("manager: suggestions: %v", err)
+		return
+	}
+	if n := SuggestBatchSize - len(ids); n > 0 {
+		due, err := m.refreshDue(int64(n))
+		if err != nil {
+			log.Printf("manager: suggestions: %v", err)
+		}
+		ids = append(ids, due...)
+	}
+	for i, id := range ids {
+		if !m.leads(token) {
+			// hand the remaining accounts back for the next leader
+			if err := m.queueSuggestions(ids[i:]...); err != nil {
+				log.Printf("manager: suggestions: %v", err)
+			}
+			return
+		}
+		if err := m.storeSuggestions(id); err != nil {
+			log.Printf("manager: suggestions: %s: %v", id, err)
+			if err := m.queueSuggestions(id); err != nil {
+				log.Printf("manager: suggestions: %s: %v", id, err)
+			}
+		}
+	}
+}
+
+// refreshDue claims up to count accounts whose suggestions are due a refresh
+func (m *Manager) refreshDue(count int64) ([]string, error) {
+	due, err := m.dba.ZRangeByScore(KySuggestRefresh, 0, float64(time.Now().UnixNano()), count)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, 0, len(due))
+	for _, buid := range due {
+		if n, err := m.dba.ZRem(KySuggestRefresh, buid); err != nil || n == 0 {
+			continue
+		}
+		out = append(out, buid)
+	}
+	return out, nil
+}
+
+// storeSuggestions computes and stores the suggestions of buid and schedules
+// their refresh
+func (m *Manager) storeSuggestions(buid string) error {
+	out, err := m.suggest(buid)
+	if err != nil {
+		return err
+	}
+	buf := &bytes.Buffer{}
+	if err := gob.NewEncoder(buf).Encode(out); err != nil {
+		return err
+	}
+	if err := m.dba.SetBytesExpire(fmt.Sprintf(PaSuggestions, buid), buf.Bytes(), DefaultSuggestionTTL); err != nil {
+		return err
+	}
+	due := time.Now().Add(SuggestionRefresh)
+	return m.dba.ZAdd(KySuggestRefresh, float64(due.UnixNano()), buid)
+}
+
+// excluded returns accounts that must not be suggested to buid; itself, friends,
+// pending requests in either direction, blocks, and dismissed suggestions.
+func (m *Manager) excluded(buid string) (map[string]bool, error) {
+	out, err := m.blocked(buid)
+	if err != nil {
+		return nil, err
+	}
+	out[buid] = true
+	for _, pattern := range []string{PaFriends, PaRequestsIn, PaRequestsOut, PaDismissed} {
+		ids, err := m.dba.SMembers(fmt.Sprintf(pattern, buid))
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			out[id] = true
+		}
+	}
+	return out, nil
+}
+
+// suggest scores candidates for buid. Candidates are second degree connections
+// and recent co-players; shared products, country, and language only add to
+// the score of those candidates.
+func (m *Manager) suggest(buid string) ([]*Suggestion, error) {
+	skip, err := m.excluded(buid)
+	if err != nil {
+		return nil, err
+	}
+	conns, err := m.Connections(buid, 2, MaxSuggestionCandidates)
+	if err != nil {
+		return nil, err
+	}
+	played, err := m.recent(buid)
+	if err != nil {
+		return nil, err
+	}
+
+	found := map[string]*Suggestion{}
+	add := func(id string) *Suggestion {
+		s, ok := found[id]
+		if !ok {
+			s = &Suggestion{BUID: id, Reasons: []string{}}
+			found[id] = s
+		}
+		return s
+	}
+	for _, c := range conns {
+		if skip[c.BUID] {
+			continue
+		}
+		s := add(c.BUID)
+		s.Mutual = c.Mutual
+		s.Score += WeightMutual * c.Mutual
+		s.Reasons = append(s.Reasons, ReasonMutual)
+	}
+	cutoff := time.Now().Add(-CoPlayWindow)
+	for _, p := range played {
+		if skip[p.BUID] || p.Time.Before(cutoff) || len(found) >= MaxSuggestionCandidates {
+			continue
+		}
+		s := add(p.BUID)
+		s.Score += WeightCoPlay
+		s.Reasons = append(s.Reasons, ReasonCoPlay)
+	}
+	if len(found) == 0 {
+		return []*Suggestion{}, nil
+	}
+
+	ids := make([]string, 0, len(found)+1)
+	for id := range found {
+		ids = append(ids, id)
+	}
+	if err := m.affinity(buid, found); err != nil {
+		return nil, err
+	}
+	accounts := map[string]*Account{}
+	if m.identity != nil {
+		if accounts, err = m.GetAccounts(append(ids, buid)...); err != nil {
+			log.Printf("manager: suggest: %v", err)
+		}
+	}
+	self := accounts[buid]
+	for id, s := range found {
+		a, ok := accounts[id]
+		if !ok {
+			continue
+		}
+		s.Name = a.Name
+		if self == nil {
+			continue
+		}
+		if a.Country != "" && a.Country == self.Country {
+			s.Score += WeightCountry
+			s.Reasons = append(s.Reasons, ReasonCountry)
+		}
+		if a.Language != "" && a.Language == self.Language {
+			s.Score += WeightLanguage
+			s.Reasons = append(s.Reasons, ReasonLanguage)
+		}
+	}
+
+	out := make([]*Suggestion, 0, len(found))
+	for _, s := range found {
+		out = append(out, s)
+	}
+	if out, err = m.requestable(out); err != nil {
+		return nil, err
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		return out[i].BUID < out[j].BUID
+	})
+	if len(out) > MaxSuggestions {
+		out = out[:MaxSuggestions]
+	}
+	return out, nil
+}
+
+// requestable returns the suggestions which are searchable and accept a friend
+// request from the account they are suggested to
+func (m *Manager) requestable(in []*Suggestion) ([]*Suggestion, error) {
+	ids := make([]string, len(in))
+	for i, s := range in {
+		ids[i] = s.BUID
+	}
+	settings, err := m.privacy(ids...)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*Suggestion, 0, len(in))
+	for _, s := range in {
+		if p := settings[s.BUID]; p.Searchable && p.allowsRequest(s.Mutual) {
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+// affinity adds the products buid shares with each candidate to their score
+func (m *Manager) affinity(buid string, found map[string]*Suggestion) error {
+	if n, err := m.dba.SCard(fmt.Sprintf(PaProducts, buid)); err != nil || n == 0 {
+		return err
+	}
+	for id, s := range found {
+		shared, err := m.dba.SInter(fmt.Sprintf(PaProducts, buid), fmt.Sprintf(PaProducts, id))
+		if err != nil {
+			return err
+		}
+		if len(shared) > 0 {
+			s.Score += WeightProduct * len(shared)
+			s.Reasons = append(s.Reasons, ReasonProduct)
+		}
+	}
+	return nil
+}
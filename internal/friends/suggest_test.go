@@ -0,0 +1,128 @@
+package friends
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BethesdaNet/friends-go/internal/db/redis"
+	"github.com/BethesdaNet/friends-go/internal/friends/status"
+	goredis "github.com/go-redis/redis"
+)
+
+func TestSuggestions(t *testing.T) {
+	m, c := newTestManager()
+	for _, edge := range [][2]string{{"a", "b"}, {"a", "c"}, {"b", "d"}, {"c", "d"}, {"b", "e"}, {"c", "f"}} {
+		if err := m.addFriend(edge[0], edge[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.AddRoster(Roster{Product: "fallout", Platform: "pc", BUIDs: []string{"a", "g"}}); err != nil {
+		t.Fatal(err)
+	}
+	m.SetStatus("a", "fallout", "pc", "", "", (&Status{}).Set(status.Online))
+	m.SetStatus("f", "fallout", "pc", "", "", (&Status{}).Set(status.Online))
+
+	t.Run("Queue", func(t *testing.T) {
+		out, err := m.GetSuggestions("a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(out) != 0 {
+			t.Errorf("got %v; want %v", len(out), 0)
+		}
+		m.precompute(m.token())
+		if n := len(c.sets[KySuggestQueue]); n != 0 {
+			t.Errorf("got %v; want %v", n, 0)
+		}
+	})
+	/* ------------------------------------------------------------------------ */
+	t.Run("Score", func(t *testing.T) {
+		out, err := m.GetSuggestions("a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := []string{}
+		for _, s := range out {
+			got = append(got, s.BUID)
+		}
+		if want := "g,d,f,e"; strings.Join(got, ",") != want {
+			t.Errorf("got %v; want %v", got, want)
+		}
+	})
+	/* ------------------------------------------------------------------------ */
+	t.Run("Excluded", func(t *testing.T) {
+		if err := m.DismissSuggestion("a", "g"); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Block("a", "d"); err != nil {
+			t.Fatal(err)
+		}
+		out, err := m.GetSuggestions("a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(out) != 2 || out[0].BUID != "f" {
+			t.Errorf("got %+v; want %v", out, "f,e")
+		}
+	})
+	/* ------------------------------------------------------------------------ */
+	t.Run("Searchable", func(t *testing.T) {
+		m.SetPrivacy("f", &Privacy{Requests: AudienceEveryone, Presence: AudienceEveryone})
+		if err := m.storeSuggestions("a"); err != nil {
+			t.Fatal(err)
+		}
+		out, err := m.GetSuggestions("a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(out) != 1 || out[0].BUID != "e" {
+			t.Errorf("got %+v; want %v", out, "e")
+		}
+	})
+	/* ------------------------------------------------------------------------ */
+	t.Run("Refresh", func(t *testing.T) {
+		if due := c.zsets[KySuggestRefresh]["a"]; due < float64(time.Now().UnixNano()) {
+			t.Fatalf("got %v; want %v", due, "scheduled")
+		}
+		c.zsets[KySuggestRefresh]["a"] = 1
+		delete(c.kv, fmt.Sprintf(PaSuggestions, "a"))
+		m.precompute(m.token())
+		if _, ok := c.kv[fmt.Sprintf(PaSuggestions, "a")]; !ok {
+			t.Errorf("got %v; want %v", ok, true)
+		}
+		if due := c.zsets[KySuggestRefresh]["a"]; due < float64(time.Now().UnixNano()) {
+			t.Errorf("got %v; want %v", due, "rescheduled")
+		}
+	})
+	/* ------------------------------------------------------------------------ */
+	t.Run("Retry", func(t *testing.T) {
+		dba := m.dba
+		m.dba = redis.NewAgent(&brokenClient{c, fmt.Sprintf(PaFriends, "b")}, redis.Config{TTL: time.Minute, Clustered: true})
+		m.queueSuggestions("b")
+		m.precompute(m.token())
+		if !c.sets[KySuggestQueue]["b"] {
+			t.Errorf("got %v; want %v", false, true)
+		}
+		m.dba = dba
+		m.precompute(m.token())
+		if c.sets[KySuggestQueue]["b"] {
+			t.Errorf("got %v; want %v", true, false)
+		}
+	})
+}
+
+// brokenClient fails reads of one set
+type brokenClient struct {
+	*memClient
+	broken string
+}
+
+func (c *brokenClient) SMembers(k string) *redis.StringSliceCmd {
+	if k == c.broken {
+		return goredis.NewStringSliceResult(nil, errors.New("broken"))
+	}
+	return c.memClient.SMembers(k)
+}
@@ -0,0 +1,31 @@
+package friends
+
+import (
+	"github.com/BethesdaNet/friends-go/internal/friends/status"
+)
+
+// ViewStatuses returns the statuses of buids as seen by viewer. Statuses of
+// accounts blocked by or blocking the viewer, or hiding their presence from the
+// viewer, are returned offline so neither is revealed.
+func (m *Manager) ViewStatuses(viewer string, buids []string, product, platform string) (map[string]*Status, error) {
+	if len(buids) > MaxMultiStatus {
+		return nil, ErrMaxMultiStatus
+	}
+	statuses, err := m.statuses(buids, product, platform)
+	if err != nil {
+		return nil, err
+	}
+	blocked, err := m.blocked(viewer)
+	if err != nil {
+		return nil, err
+	}
+	for id, s := range statuses {
+		if id != viewer && blocked[id] {
+			statuses[id] = s.Set(status.Offline)
+		}
+	}
+	if err := m.conceal(viewer, statuses); err != nil {
+		return nil, err
+	}
+	return statuses, nil
+}
@@ -43,6 +43,9 @@ const (
 	// HeaderFinger header for client fingerprint
 	HeaderFinger = "X-Src-fp"
 
+	// HeaderLanguage header for client language and country (en-US)
+	HeaderLanguage = "Accept-Language"
+
 	// HeaderContent header is for the content type
 	HeaderContent = "content-type"
 
@@ -0,0 +1,46 @@
+package platform
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+const (
+	// CodeOK platform code returned on successful requests
+	CodeOK = 2000
+
+	// CodeError platform code offset added to the http status on failed requests
+	CodeError = 1000
+)
+
+// Reply is the standard platform envelope wrapping every response body
+type Reply struct {
+	Platform Envelope `json:"platform"`
+}
+
+// Envelope contains the platform code and either the response or error message
+type Envelope struct {
+	Code     int         `json:"code"`
+	Message  string      `json:"message,omitempty"`
+	Response interface{} `json:"response,omitempty"`
+}
+
+// Write encodes data in the platform envelope. Status codes 400 and above are
+// written as errors where data is expected to be the error message.
+func Write(w http.ResponseWriter, status int, data interface{}) error {
+	rep := Reply{}
+	if status >= http.StatusBadRequest {
+		rep.Platform.Code = CodeError + status
+		if err, ok := data.(error); ok {
+			rep.Platform.Message = err.Error()
+		} else if msg, ok := data.(string); ok {
+			rep.Platform.Message = msg
+		}
+	} else {
+		rep.Platform.Code = CodeOK
+		rep.Platform.Response = data
+	}
+	w.Header().Set(HeaderContent, "application/json")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(&rep)
+}
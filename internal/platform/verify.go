@@ -0,0 +1,257 @@
+package platform
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultKeyMaxAge used as the max age of a key if the verifier config is zero
+const DefaultKeyMaxAge = time.Hour * 24
+
+// KeySkew allows for clock drift between the key issuer and this service when
+// checking the creation time of a key
+const KeySkew = time.Minute * 5
+
+var (
+	// ErrKeyFormat returned when the key is not a compact signed token
+	ErrKeyFormat = errors.New("invalid key format")
+
+	// ErrKeySignature returned when the key signature does not verify
+	ErrKeySignature = errors.New("invalid key signature")
+
+	// ErrKeyExpired returned when the key creation time is older than max age or
+	// is set in the future
+	ErrKeyExpired = errors.New("key expired")
+
+	// ErrKeyKind returned when the key type is not allowed by the verifier
+	ErrKeyKind = errors.New("key type not allowed")
+
+	// ErrKeyID returned when the key was signed by an unknown key id
+	ErrKeyID = errors.New("unknown key id")
+
+	// ErrKeySet returned when the key set could not be loaded or parsed
+	ErrKeySet = errors.New("invalid key set")
+)
+
+// VerifierConfig controls how bnet keys are verified
+type VerifierConfig struct {
+
+	// Path of the json web key set (jwks) file used to verify key signatures
+	Path string `json:"path"`
+
+	// MaxAge of a key measured from its creation time (Key.Ctime)
+	MaxAge time.Duration `json:"max_age"`
+
+	// Kinds of keys (Key.Kind) allowed. All kinds are allowed if empty.
+	Kinds []string `json:"kinds"`
+}
+
+// Verifier decodes and verifies the encoded bnet key sent in HeaderKey. Keys are
+// compact signed tokens (header.payload.signature) whose payload is the Key.
+type Verifier struct {
+	keys   map[string]interface{}
+	kinds  map[string]bool
+	maxAge time.Duration
+
+	// now returns the current time and is replaced during testing
+	now func() time.Time
+}
+
+// NewVerifier creates a verifier using the key set loaded from c.Path
+func NewVerifier(c VerifierConfig) (*Verifier, error) {
+	data, err := ioutil.ReadFile(c.Path)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := ParseKeySet(data)
+	if err != nil {
+		return nil, err
+	}
+	if c.MaxAge <= 0 {
+		c.MaxAge = DefaultKeyMaxAge
+	}
+	v := &Verifier{
+		keys:   keys,
+		kinds:  make(map[string]bool, len(c.Kinds)),
+		maxAge: c.MaxAge,
+		now:    time.Now,
+	}
+	for _, k := range c.Kinds {
+		v.kinds[k] = true
+	}
+	return v, nil
+}
+
+// Verify decodes raw, checks its signature, creation time, and kind, and returns
+// the decoded key
+func (v *Verifier) Verify(raw string) (*Key, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, ErrKeyFormat
+	}
+	head := struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}{}
+	if err := decodeSegment(parts[0], &head); err != nil {
+		return nil, ErrKeyFormat
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrKeyFormat
+	}
+	pub, ok := v.keys[head.Kid]
+	if !ok {
+		return nil, ErrKeyID
+	}
+	if err := verify(head.Alg, pub, parts[0]+"."+parts[1], sig); err != nil {
+		return nil, err
+	}
+
+	key := &Key{}
+	if err := decodeSegment(parts[1], key); err != nil {
+		return nil, ErrKeyFormat
+	}
+	now := v.now()
+	ctime := time.Unix(int64(key.Ctime), 0)
+	if key.Ctime <= 0 || ctime.After(now.Add(KeySkew)) || now.Sub(ctime) > v.maxAge {
+		return nil, ErrKeyExpired
+	}
+	if len(v.kinds) > 0 && !v.kinds[key.Kind] {
+		return nil, ErrKeyKind
+	}
+	return key, nil
+}
+
+// Apply verifies the key sent in HeaderKey and applies it to the request headers
+func (v *Verifier) Apply(r *http.Request) error {
+	raw := r.Header.Get(HeaderKey)
+	if raw == "" {
+		return ErrInvalidKey
+	}
+	key, err := v.Verify(raw)
+	if err != nil {
+		return err
+	}
+	return key.ApplyTo(r)
+}
+
+func verify(alg string, pub interface{}, signed string, sig []byte) error {
+	sum := sha256.Sum256([]byte(signed))
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		if alg != "RS256" {
+			return ErrKeySignature
+		}
+		if rsa.VerifyPKCS1v15(k, crypto.SHA256, sum[:], sig) != nil {
+			return ErrKeySignature
+		}
+	case *ecdsa.PublicKey:
+		if alg != "ES256" || len(sig) != 64 {
+			return ErrKeySignature
+		}
+		r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+		if !ecdsa.Verify(k, sum[:], r, s) {
+			return ErrKeySignature
+		}
+	case []byte:
+		if alg != "HS256" {
+			return ErrKeySignature
+		}
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		if subtle.ConstantTimeCompare(mac.Sum(nil), sig) != 1 {
+			return ErrKeySignature
+		}
+	default:
+		return ErrKeySignature
+	}
+	return nil
+}
+
+// ParseKeySet parses a json web key set returning public keys by key id. RSA,
+// EC (P-256), and symmetric (oct) keys are supported.
+func ParseKeySet(data []byte) (map[string]interface{}, error) {
+	set := struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Crv string `json:"crv"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+			K   string `json:"k"`
+		} `json:"keys"`
+	}{}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, ErrKeySet
+	}
+	out := make(map[string]interface{}, len(set.Keys))
+	for _, k := range set.Keys {
+		switch k.Kty {
+		case "RSA":
+			n, err1 := decodeInt(k.N)
+			e, err2 := decodeInt(k.E)
+			if err1 != nil || err2 != nil || !e.IsInt64() {
+				return nil, ErrKeySet
+			}
+			out[k.Kid] = &rsa.PublicKey{N: n, E: int(e.Int64())}
+		case "EC":
+			x, err1 := decodeInt(k.X)
+			y, err2 := decodeInt(k.Y)
+			if err1 != nil || err2 != nil || k.Crv != "P-256" {
+				return nil, ErrKeySet
+			}
+			pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+			if !pub.Curve.IsOnCurve(x, y) {
+				return nil, ErrKeySet
+			}
+			out[k.Kid] = pub
+		case "oct":
+			b, err := base64.RawURLEncoding.DecodeString(k.K)
+			if err != nil || len(b) == 0 {
+				return nil, ErrKeySet
+			}
+			out[k.Kid] = b
+		default:
+			return nil, ErrKeySet
+		}
+	}
+	if len(out) == 0 {
+		return nil, ErrKeySet
+	}
+	return out, nil
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func decodeInt(v string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, ErrKeySet
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,110 @@
+package platform
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestVerifier(t *testing.T) {
+	rk, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ek, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	b64 := base64.RawURLEncoding.EncodeToString
+
+	set := fmt.Sprintf(`{"keys":[{"kid":"rsa","kty":"RSA","n":%q,"e":%q},{"kid":"ec","kty":"EC","crv":"P-256","x":%q,"y":%q}]}`,
+		b64(rk.N.Bytes()), b64([]byte{1, 0, 1}), b64(ek.X.Bytes()), b64(ek.Y.Bytes()))
+	dir, err := ioutil.TempDir("", "verify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "jwks.json")
+	if err := ioutil.WriteFile(path, []byte(set), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	v, err := NewVerifier(VerifierConfig{Path: path, MaxAge: time.Hour, Kinds: []string{"client"}})
+	if err != nil {
+		t.Fatalf("got %v; want %v", err, nil)
+	}
+	v.now = func() time.Time { return now }
+
+	sign := func(kid, alg string, key Key) string {
+		head, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid})
+		body, _ := json.Marshal(key)
+		signed := b64(head) + "." + b64(body)
+		sum := sha256.Sum256([]byte(signed))
+		var sig []byte
+		switch alg {
+		case "RS256":
+			sig, _ = rsa.SignPKCS1v15(rand.Reader, rk, crypto.SHA256, sum[:])
+		case "ES256":
+			r, s, _ := ecdsa.Sign(rand.Reader, ek, sum[:])
+			sig = make([]byte, 64)
+			rb, sb := r.Bytes(), s.Bytes()
+			copy(sig[32-len(rb):32], rb)
+			copy(sig[64-len(sb):], sb)
+		}
+		return signed + "." + b64(sig)
+	}
+	good := Key{Key: "abcd", Kind: "client", Ctime: int(now.Add(-time.Minute).Unix()), Product: Product{Name: "doom", Datascope: "prod"}}
+
+	t.Run("Good", func(t *testing.T) {
+		for _, c := range []struct{ kid, alg string }{{"rsa", "RS256"}, {"ec", "ES256"}} {
+			key, err := v.Verify(sign(c.kid, c.alg, good))
+			if err != nil {
+				t.Fatalf("%s: got %v; want %v", c.alg, err, nil)
+			}
+			if key.Product.Name != good.Product.Name {
+				t.Errorf("%s: got %s; want %s", c.alg, key.Product.Name, good.Product.Name)
+			}
+		}
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(HeaderKey, sign("rsa", "RS256", good))
+		if err := v.Apply(r); err != nil {
+			t.Fatalf("got %v; want %v", err, nil)
+		}
+		if got := r.Header.Get(HeaderProduct); got != good.Product.Name {
+			t.Errorf("got %s; want %s", got, good.Product.Name)
+		}
+	})
+	/* ------------------------------------------------------------------------ */
+	t.Run("Bad", func(t *testing.T) {
+		expired, future, server := good, good, good
+		expired.Ctime = int(now.Add(-time.Hour * 2).Unix())
+		future.Ctime = int(now.Add(time.Hour).Unix())
+		server.Kind = "server"
+		tampered := sign("rsa", "RS256", good)
+		tampered = tampered[:len(tampered)-4] + "AAAA"
+
+		for name, c := range map[string]struct {
+			raw  string
+			want error
+		}{
+			"Format":    {"abcd", ErrKeyFormat},
+			"KeyID":     {sign("golf", "RS256", good), ErrKeyID},
+			"Alg":       {sign("rsa", "ES256", good), ErrKeySignature},
+			"Signature": {tampered, ErrKeySignature},
+			"Expired":   {sign("ec", "ES256", expired), ErrKeyExpired},
+			"Future":    {sign("ec", "ES256", future), ErrKeyExpired},
+			"Kind":      {sign("rsa", "RS256", server), ErrKeyKind},
+		} {
+			if _, err := v.Verify(c.raw); err != c.want {
+				t.Errorf("%s: got %v; want %v", name, err, c.want)
+			}
+		}
+	})
+}
@@ -0,0 +1,284 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/BethesdaNet/friends-go/internal/client"
+)
+
+// BreakerState of a circuit breaker
+type BreakerState int
+
+const (
+	// BreakerClosed breakers pass every request through to the provider
+	BreakerClosed BreakerState = iota
+
+	// BreakerOpen breakers fail every request without reaching the provider
+	BreakerOpen
+
+	// BreakerHalfOpen breakers let a limited number of probes through to decide
+	// if the provider has recovered
+	BreakerHalfOpen
+)
+
+var breakerStates = [...]string{
+	BreakerClosed:   "closed",
+	BreakerOpen:     "open",
+	BreakerHalfOpen: "half-open",
+}
+
+// String returns the state in human readable format
+func (s BreakerState) String() string {
+	return breakerStates[s]
+}
+
+// MarshalJSON encodes the state as its string value
+func (s BreakerState) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + s.String() + `"`), nil
+}
+
+const (
+	// DefaultBreakerWindow is the rolling window the error rate is measured over
+	DefaultBreakerWindow = time.Second * 10
+
+	// DefaultBreakerMinRequests is the min requests in the window before a
+	// breaker may trip
+	DefaultBreakerMinRequests = 20
+
+	// DefaultBreakerFailureRatio trips the breaker if reached by failed requests
+	DefaultBreakerFailureRatio = 0.5
+
+	// DefaultBreakerSlowRatio trips the breaker if reached by slow requests
+	DefaultBreakerSlowRatio = 0.8
+
+	// DefaultBreakerSlowCall is the latency above which a request counts as slow
+	DefaultBreakerSlowCall = time.Second
+
+	// DefaultBreakerCooldown is how long a breaker stays open before probing
+	DefaultBreakerCooldown = time.Second * 5
+
+	// DefaultBreakerProbes is the number of successful probes required to close
+	DefaultBreakerProbes = 3
+)
+
+// ErrBreakerOpen returned without reaching the provider while its breaker is open
+var ErrBreakerOpen = errors.New("error provider unavailable; circuit open")
+
+// BreakerConfig controls when a breaker trips and recovers. Zero values are
+// replaced with the defaults above.
+type BreakerConfig struct {
+	Window       time.Duration `json:"window"`
+	MinRequests  int           `json:"min_requests"`
+	FailureRatio float64       `json:"failure_ratio"`
+	SlowRatio    float64       `json:"slow_ratio"`
+	SlowCall     time.Duration `json:"slow_call"`
+	Cooldown     time.Duration `json:"cooldown"`
+	Probes       int           `json:"probes"`
+}
+
+// NewBreaker wraps c in a circuit breaker named after the provider
+func NewBreaker(name string, c Client, conf BreakerConfig) *Breaker {
+	if conf.Window <= 0 {
+		conf.Window = DefaultBreakerWindow
+	}
+	if conf.MinRequests <= 0 {
+		conf.MinRequests = DefaultBreakerMinRequests
+	}
+	if conf.FailureRatio <= 0 {
+		conf.FailureRatio = DefaultBreakerFailureRatio
+	}
+	if conf.SlowRatio <= 0 {
+		conf.SlowRatio = DefaultBreakerSlowRatio
+	}
+	if conf.SlowCall <= 0 {
+		conf.SlowCall = DefaultBreakerSlowCall
+	}
+	if conf.Cooldown <= 0 {
+		conf.Cooldown = DefaultBreakerCooldown
+	}
+	if conf.Probes <= 0 {
+		conf.Probes = DefaultBreakerProbes
+	}
+	return &Breaker{
+		name:   name,
+		client: c,
+		conf:   conf,
+		since:  time.Now(),
+		now:    time.Now,
+	}
+}
+
+// Breaker is a circuit breaker implementing Client. It trips open once the error
+// rate or slow call rate within the rolling window crosses the configured ratio,
+// fails fast while open, and after a cooldown lets probes through half-open.
+type Breaker struct {
+	name   string
+	client Client
+	conf   BreakerConfig
+
+	mu     sync.Mutex
+	state  BreakerState
+	since  time.Time
+	bucket [breakerBuckets]bucket
+
+	// probes let through and probes passed while half-open
+	probes, passed int
+
+	// now returns the current time and is replaced during testing
+	now func() time.Time
+}
+
+const breakerBuckets = 10
+
+// bucket counts outcomes for a slice of the rolling window
+type bucket struct {
+	start    time.Time
+	requests int
+	failures int
+	slow     int
+}
+
+// BreakerStats reports the breaker state and the counts in the current window
+type BreakerStats struct {
+	Name     string       `json:"name"`
+	State    BreakerState `json:"state"`
+	Since    time.Time    `json:"since"`
+	Requests int          `json:"requests"`
+	Failures int          `json:"failures"`
+	Slow     int          `json:"slow"`
+}
+
+// Do sends the request through the wrapped client unless the breaker is open
+func (b *Breaker) Do(r *http.Request, data interface{}) error {
+	if !b.allow() {
+		return ErrBreakerOpen
+	}
+	start := b.now()
+	err := b.client.Do(r, data)
+	b.record(failed(err), b.now().Sub(start) > b.conf.SlowCall)
+	return err
+}
+
+// Stats returns the breaker state and window counts
+func (b *Breaker) Stats() BreakerStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := BreakerStats{Name: b.name, State: b.state, Since: b.since}
+	for _, bk := range b.window() {
+		s.Requests += bk.requests
+		s.Failures += bk.failures
+		s.Slow += bk.slow
+	}
+	return s
+}
+
+func (b *Breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.since) < b.conf.Cooldown {
+			return false
+		}
+		b.transition(BreakerHalfOpen)
+		fallthrough
+	case BreakerHalfOpen:
+		// only allow as many concurrent probes as required to close the breaker
+		if b.probes >= b.conf.Probes {
+			return false
+		}
+		b.probes++
+	}
+	return true
+}
+
+func (b *Breaker) record(fail, slow bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerHalfOpen:
+		if fail || slow {
+			b.transition(BreakerOpen)
+			return
+		}
+		if b.passed++; b.passed >= b.conf.Probes {
+			b.transition(BreakerClosed)
+		}
+		return
+	case BreakerOpen:
+		return
+	}
+
+	now := b.now()
+	width := b.conf.Window / breakerBuckets
+	slot := now.UnixNano() / int64(width)
+	bk := &b.bucket[slot%breakerBuckets]
+	if start := time.Unix(0, slot*int64(width)); !bk.start.Equal(start) {
+		*bk = bucket{start: start}
+	}
+	bk.requests++
+	if fail {
+		bk.failures++
+	}
+	if slow {
+		bk.slow++
+	}
+
+	var requests, failures, slows int
+	for _, w := range b.window() {
+		requests += w.requests
+		failures += w.failures
+		slows += w.slow
+	}
+	if requests < b.conf.MinRequests {
+		return
+	}
+	if float64(failures)/float64(requests) >= b.conf.FailureRatio || float64(slows)/float64(requests) >= b.conf.SlowRatio {
+		b.transition(BreakerOpen)
+	}
+}
+
+// window returns the buckets within the rolling window
+func (b *Breaker) window() []bucket {
+	out := make([]bucket, 0, breakerBuckets)
+	now := b.now()
+	for _, bk := range b.bucket {
+		if now.Sub(bk.start) < b.conf.Window {
+			out = append(out, bk)
+		}
+	}
+	return out
+}
+
+func (b *Breaker) transition(to BreakerState) {
+	if b.state == to {
+		return
+	}
+	log.Printf("breaker: %s: %s -> %s", b.name, b.state, to)
+	b.state, b.since, b.probes, b.passed = to, b.now(), 0, 0
+	if to != BreakerHalfOpen {
+		b.bucket = [breakerBuckets]bucket{}
+	}
+}
+
+// Temporary returns true if a request failing with err may succeed when retried
+func Temporary(err error) bool {
+	return failed(err)
+}
+
+// failed returns true if err should count against the provider. Platform errors
+// below 500 are valid replies and callers cancelling requests are not failures.
+func failed(err error) bool {
+	if err == nil || err == context.Canceled {
+		return false
+	}
+	if pe, ok := err.(client.PlatformError); ok {
+		return pe.Code >= http.StatusInternalServerError && pe.Code < 600
+	}
+	return true
+}
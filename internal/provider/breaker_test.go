@@ -0,0 +1,76 @@
+package provider
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type stubClient struct {
+	err   error
+	delay time.Duration
+	calls int
+	now   *time.Time
+}
+
+func (c *stubClient) Do(r *http.Request, data interface{}) error {
+	c.calls++
+	*c.now = c.now.Add(c.delay)
+	return c.err
+}
+
+func TestBreaker(t *testing.T) {
+	now := time.Now()
+	stub := &stubClient{now: &now}
+	b := NewBreaker("identity", stub, BreakerConfig{MinRequests: 4, Cooldown: time.Second, Probes: 2})
+	b.now = func() time.Time { return now }
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+
+	t.Run("Trip", func(t *testing.T) {
+		stub.err = errors.New("connection refused")
+		for i := 0; i < 4; i++ {
+			b.Do(r, nil)
+		}
+		if s := b.Stats(); s.State != BreakerOpen {
+			t.Fatalf("got %s; want %s", s.State, BreakerOpen)
+		}
+		calls := stub.calls
+		if err := b.Do(r, nil); err != ErrBreakerOpen {
+			t.Errorf("got %v; want %v", err, ErrBreakerOpen)
+		}
+		if stub.calls != calls {
+			t.Errorf("got %d; want %d", stub.calls, calls)
+		}
+	})
+	/* ------------------------------------------------------------------------ */
+	t.Run("Probe", func(t *testing.T) {
+		now = now.Add(time.Second * 2)
+		if err := b.Do(r, nil); err == ErrBreakerOpen {
+			t.Fatalf("got %v; want %s", err, "probe")
+		}
+		if s := b.Stats(); s.State != BreakerOpen {
+			t.Fatalf("got %s; want %s", s.State, BreakerOpen)
+		}
+		now = now.Add(time.Second * 2)
+		stub.err = nil
+		for i := 0; i < 2; i++ {
+			if err := b.Do(r, nil); err != nil {
+				t.Fatalf("got %v; want %v", err, nil)
+			}
+		}
+		if s := b.Stats(); s.State != BreakerClosed {
+			t.Errorf("got %s; want %s", s.State, BreakerClosed)
+		}
+	})
+	/* ------------------------------------------------------------------------ */
+	t.Run("Slow", func(t *testing.T) {
+		stub.delay = DefaultBreakerSlowCall * 2
+		for i := 0; i < 4; i++ {
+			b.Do(r, nil)
+		}
+		if s := b.Stats(); s.State != BreakerOpen {
+			t.Errorf("got %s; want %s", s.State, BreakerOpen)
+		}
+	})
+}
@@ -35,6 +35,18 @@ type Config struct {
 	// Timeout duration for provider requests (http_request_timeout_sec)
 	Timeout time.Duration `json:"timeout"`
 
+	// Retries is the max number of retries of idempotent requests after network
+	// errors or 5xx replies. Every attempt shares the Timeout of the request.
+	// Zero uses client.DefaultRetries and below zero disables retries.
+	Retries int `json:"retries"`
+
+	// CacheSize enables the provider lookup cache when greater than zero and
+	// limits how many entries are held before least recently used are evicted
+	CacheSize int `json:"cache_size"`
+
+	// CacheTTL is how long cached lookups are served before being refreshed
+	CacheTTL time.Duration `json:"cache_ttl"`
+
 	// key holds onto the providers required key
 	Key string `json:"-"`
 }
@@ -18,6 +18,9 @@ var (
 	// ErrInvalidBUID error returned if buid is invalid
 	ErrInvalidBUID = errors.New("error invalid buid")
 
+	// ErrAccountNotFound error returned if identity returned no account
+	ErrAccountNotFound = errors.New("error account not found")
+
 	// ErrNilClient error when provider client is nil
 	ErrNilClient = errors.New("error nil client")
 )
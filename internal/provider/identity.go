@@ -4,7 +4,9 @@ import (
 	"context"
 	"net/http"
 	"strings"
+	"sync"
 
+	"github.com/BethesdaNet/friends-go/internal/client"
 	"github.com/BethesdaNet/friends-go/internal/platform"
 	"github.com/BethesdaNet/friends-go/internal/provider/identity"
 )
@@ -22,33 +24,138 @@ type Identity struct {
 type IdentityConfig struct {
 	Config
 	LookupURL string `json:"lookup_url"`
+
+	// BatchSize limits how many ids are sent in a single lookup
+	BatchSize int `json:"batch_size"`
+
+	// MaxURLLength limits the length of a single lookup url. Ids are split into
+	// another batch before the url would exceed it.
+	MaxURLLength int `json:"max_url_length"`
+
+	// Concurrency limits how many batches are looked up at once
+	Concurrency int `json:"concurrency"`
 }
 
+const (
+	// DefaultLookupBatchSize used if the identity batch size is zero
+	DefaultLookupBatchSize = 100
+
+	// DefaultLookupURLLength used if the identity max url length is zero
+	DefaultLookupURLLength = 2048
+
+	// DefaultLookupConcurrency used if the identity concurrency is zero
+	DefaultLookupConcurrency = 4
+)
+
 // Close method for cleaning tearing down the identity provider.
 func (p *Identity) Close() {}
 
 // GetAccount retrieves accounts by buid
 func (p *Identity) GetAccount(id string, in *Account) error {
 	data := []*Account{}
-	if err := p.getAccounts([]string{id}, data); err != nil {
+	if err := p.getAccounts([]string{id}, &data); err != nil {
 		return err
 	}
+	if len(data) == 0 || data[0] == nil {
+		return ErrAccountNotFound
+	}
+	*in = *data[0]
 	return nil
 }
 
 // GetAccounts retrieves accounts by buid array
-func (p *Identity) GetAccounts(id []string, data []*Account) error {
+func (p *Identity) GetAccounts(id []string, data *[]*Account) error {
 	if err := p.getAccounts(id, data); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (p *Identity) getAccounts(id []string, data []*Account) error {
-	r, _ := http.NewRequest(http.MethodGet, p.Addr+p.LookupURL+strings.Join(id, ","), nil)
+// getAccounts serves accounts from the provider cache when enabled and looks up
+// the remaining ids from identity, caching the accounts returned.
+func (p *Identity) getAccounts(id []string, data *[]*Account) error {
+	miss := make([]string, 0, len(id))
+	for _, v := range id {
+		if kv := p.cache.Get(p.LookupURL, v); kv != nil {
+			a := *(kv.Value().(*Account))
+			*data = append(*data, &a)
+			continue
+		}
+		miss = append(miss, v)
+	}
+	if len(miss) == 0 {
+		return nil
+	}
+
+	// look up each batch concurrently; accounts from successful batches are kept
+	// even if another batch fails so callers may render partial results
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		fail error
+		sem  = make(chan struct{}, p.Concurrency)
+	)
+	for _, batch := range chunk(miss, p.BatchSize, p.MaxURLLength-len(p.Addr+p.LookupURL)) {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(batch []string) {
+			defer func() { <-sem; wg.Done() }()
+			found := []*Account{}
+			err := p.lookup(batch, &found)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				fail = err
+				return
+			}
+			for _, a := range found {
+				if a == nil {
+					continue
+				}
+				c := *a
+				p.cache.Put(p.LookupURL, client.NewItem(a.ID, &c))
+				*data = append(*data, a)
+			}
+		}(batch)
+	}
+	wg.Wait()
+	return fail
+}
+
+// chunk splits ids into batches of at most size ids where the joined batch is
+// no longer than length bytes. A single id longer than length gets its own batch.
+func chunk(ids []string, size, length int) [][]string {
+	out := [][]string{}
+	var cur []string
+	n := 0
+	for _, id := range ids {
+		add := len(id)
+		if len(cur) > 0 {
+			add++ // separator
+		}
+		if len(cur) > 0 && (len(cur) >= size || n+add > length) {
+			out = append(out, cur)
+			cur, n, add = nil, 0, len(id)
+		}
+		cur = append(cur, id)
+		n += add
+	}
+	if len(cur) > 0 {
+		out = append(out, cur)
+	}
+	return out
+}
+
+func (p *Identity) lookup(id []string, data *[]*Account) error {
+	r, _ := http.NewRequest(http.MethodGet, p.LookupURL+strings.Join(id, ","), nil)
 	r.Header.Set(platform.HeaderKey, p.Key)
 	r.Header.Set("Content-Type", DefaultContentType)
 	ctx, cancel := context.WithTimeout(context.Background(), p.Timeout)
 	defer cancel()
 	return p.client.Do(r.WithContext(ctx), data)
 }
+
+// Forget removes the cached account of id so the next lookup reaches identity
+func (p *Identity) Forget(id string) {
+	p.cache.Del(p.LookupURL, id)
+}
@@ -0,0 +1,34 @@
+package provider
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestChunk(t *testing.T) {
+	ids := []string{"aaaa", "bbbb", "cccc", "dddd", "eeee"}
+	for name, c := range map[string]struct {
+		size, length int
+		want         []int
+	}{
+		"Size":   {2, 1000, []int{2, 2, 1}},
+		"Length": {100, 10, []int{2, 2, 1}},
+		"Both":   {1, 10, []int{1, 1, 1, 1, 1}},
+		"Tiny":   {100, 1, []int{1, 1, 1, 1, 1}},
+		"One":    {100, 1000, []int{5}},
+	} {
+		got := chunk(ids, c.size, c.length)
+		if len(got) != len(c.want) {
+			t.Errorf("%s: got %d batches; want %d", name, len(got), len(c.want))
+			continue
+		}
+		for i, batch := range got {
+			if len(batch) != c.want[i] {
+				t.Errorf("%s: batch %d: got %d; want %d", name, i, len(batch), c.want[i])
+			}
+			if joined := strings.Join(batch, ","); len(batch) > 1 && len(joined) > c.length {
+				t.Errorf("%s: batch %d: got %d bytes; want <= %d", name, i, len(joined), c.length)
+			}
+		}
+	}
+}
@@ -9,11 +9,18 @@ import (
 
 func mkIdentity(c *IdentityConfig) (Service, error) {
 	c.Timeout = ckTimeout(c.Timeout)
+	if c.BatchSize <= 0 {
+		c.BatchSize = DefaultLookupBatchSize
+	}
+	if c.MaxURLLength <= 0 {
+		c.MaxURLLength = DefaultLookupURLLength
+	}
+	if c.Concurrency <= 0 {
+		c.Concurrency = DefaultLookupConcurrency
+	}
 	return &Identity{
 		IdentityConfig: *c,
-		provider: provider{
-			client: client.New(ckScheme(c.Addr), c.Key, c.Env, nil),
-		},
+		provider:       mkProvider(c.Config),
 	}, nil
 }
 
@@ -21,9 +28,7 @@ func mkPresence(c *PresenceConfig) (Service, error) {
 	c.Timeout = ckTimeout(c.Timeout)
 	return &Presence{
 		PresenceConfig: *c,
-		provider: provider{
-			client: client.New(ckScheme(c.Addr), c.Key, c.Env, nil),
-		},
+		provider:       mkProvider(c.Config),
 	}, nil
 }
 
@@ -31,9 +36,7 @@ func mkNote(c *NoteConfig) (Service, error) {
 	c.Timeout = ckTimeout(c.Timeout)
 	return &Note{
 		NoteConfig: *c,
-		provider: provider{
-			client: client.New(ckScheme(c.Addr), c.Key, c.Env, nil),
-		},
+		provider:   mkProvider(c.Config),
 	}, nil
 }
 
@@ -41,12 +44,37 @@ func mkStorage(c *StorageConfig) (Service, error) {
 	c.Timeout = ckTimeout(c.Timeout)
 	return &Storage{
 		StorageConfig: *c,
-		provider:      provider{},
+		provider:      provider{cache: client.NoCache{}},
 	}, nil
 }
 
+// mkProvider creates the base provider with its own client and, if enabled by
+// the config, a lookup cache shared by the provider and its client
+func mkProvider(c Config) provider {
+	var cache client.Cache = client.NoCache{}
+	if c.CacheSize > 0 {
+		cache = client.NewLRU(c.CacheSize, c.CacheTTL)
+	}
+	// the timeout is shared by the first attempt and every retry; retries
+	// below zero disable retrying in the client
+	retries, attempts := c.Retries, c.Retries+1
+	switch {
+	case retries == 0:
+		attempts = client.DefaultRetries + 1
+	case retries < 0:
+		attempts = 1
+	}
+	return provider{
+		client: client.New(ckScheme(c.Addr), c.Key, c.Env, cache, client.Options{
+			Timeout: c.Timeout / time.Duration(attempts),
+			Retries: retries,
+		}),
+		cache: cache,
+	}
+}
+
 func ckScheme(addr string) string {
-	if !strings.HasPrefix(addr, "https://") {
+	if !strings.Contains(addr, "://") {
 		return "https://" + addr
 	}
 	return addr
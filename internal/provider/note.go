@@ -1,10 +1,10 @@
 package provider
 
 import (
+	"bytes"
 	"context"
-	"fmt"
+	"encoding/json"
 	"net/http"
-	"strings"
 
 	"github.com/BethesdaNet/friends-go/internal/platform"
 )
@@ -15,11 +15,14 @@ type Note struct {
 	provider
 }
 
-// NoteConfig holds all required information for the notification provider
+// NoteConfig holds all required information for the notification provider.
+// Batches are sent to BatchURL; if it is empty each notification of a batch is
+// sent on its own.
 type NoteConfig struct {
 	Config
 	AnnounceURL string `json:"announcement_url"`
 	NoteURL     string `json:"notification_url"`
+	BatchURL    string `json:"batch_url"`
 }
 
 // Notification struct used to send outbound annoucements or notifications
@@ -31,6 +34,25 @@ type Notification struct {
 	Announce bool
 }
 
+// NoteItem is a single notification of a batch. Payload must be valid json.
+type NoteItem struct {
+	Type    string          `json:"message_type"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// noteBatch is the outbound body of a batch
+type noteBatch struct {
+	BUID     string     `json:"buid"`
+	Messages []NoteItem `json:"messages"`
+}
+
+// announceBody is the outbound body of an announcement
+type announceBody struct {
+	Type    string `json:"message_type"`
+	Payload string `json:"payload"`
+	Version int    `json:"payload_version"`
+}
+
 // Close method will be called during teardown
 func (p *Note) Close() {}
 
@@ -38,28 +60,59 @@ func (p *Note) Close() {}
 func (p *Note) Announcement(mt, msg, buid string, out interface{}) error {
 	ctx, cancel := context.WithTimeout(context.Background(), p.Timeout)
 	defer cancel()
-	return p.client.Do(p.announce(ctx, mt, msg, buid, 1), out)
+	r, err := p.post(ctx, p.AnnounceURL, &announceBody{Type: mt, Payload: msg, Version: 1})
+	if err != nil {
+		return err
+	}
+	return p.client.Do(r, out)
 }
 
 // Notification method handles sending out messages to notification service
 func (p *Note) Notification(mt, msg, buid string, out interface{}) error {
 	ctx, cancel := context.WithTimeout(context.Background(), p.Timeout)
 	defer cancel()
-	return p.client.Do(p.note(ctx, mt, msg, buid), out)
+	r, err := p.post(ctx, p.NoteURL, &NoteItem{Type: mt, Payload: json.RawMessage(msg)})
+	if err != nil {
+		return err
+	}
+	return p.client.Do(r, out)
 }
 
-func (p *Note) announce(ctx context.Context, mt, msg, buid string, version int) *http.Request {
-	const bodyfmt = `{"message_type":%q,"payload":%q,"payload_version":%d}`
-	r, _ := http.NewRequest(http.MethodPost, p.Addr+p.AnnounceURL, strings.NewReader(fmt.Sprintf(bodyfmt, mt, msg, version)))
-	r.Header.Set(platform.HeaderKeyServer, p.Key)
-	r.Header.Set("Content-Type", DefaultContentType)
-	return r.WithContext(ctx)
+// Batch method sends several notifications for buid in a single request and
+// returns how many were delivered. Without a BatchURL the notifications are sent
+// one by one and those before the first failure are delivered, so a retry only
+// needs to send the rest.
+func (p *Note) Batch(buid string, items []NoteItem, out interface{}) (int, error) {
+	if p.BatchURL == "" {
+		for i, item := range items {
+			if err := p.Notification(item.Type, string(item.Payload), buid, out); err != nil {
+				return i, err
+			}
+		}
+		return len(items), nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), p.Timeout)
+	defer cancel()
+	r, err := p.post(ctx, p.BatchURL, &noteBatch{BUID: buid, Messages: items})
+	if err != nil {
+		return 0, err
+	}
+	if err := p.client.Do(r, out); err != nil {
+		return 0, err
+	}
+	return len(items), nil
 }
 
-func (p *Note) note(ctx context.Context, mt, msg, buid string) *http.Request {
-	const bodyfmt = `{"message_type":%q,"payload":%s}`
-	r, _ := http.NewRequest(http.MethodPost, p.Addr+p.NoteURL, strings.NewReader(fmt.Sprintf(bodyfmt, mt, msg)))
+func (p *Note) post(ctx context.Context, url string, body interface{}) (*http.Request, error) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	r, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
 	r.Header.Set(platform.HeaderKeyServer, p.Key)
 	r.Header.Set("Content-Type", DefaultContentType)
-	return r.WithContext(ctx)
+	return r.WithContext(ctx), nil
 }
@@ -0,0 +1,41 @@
+package provider
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestNoteBatch(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 2 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"platform":{"code":2000}}`))
+	}))
+	defer srv.Close()
+
+	items := []NoteItem{{Type: "a", Payload: []byte("{}")}, {Type: "b", Payload: []byte("{}")}, {Type: "c", Payload: []byte("{}")}}
+	for _, c := range []struct {
+		name     string
+		batchURL string
+		want     int
+	}{
+		{"OneByOne", "", 1},
+		{"Batch", "/batch", 0},
+	} {
+		// the batch request is the second call so it fails outright
+		atomic.StoreInt32(&calls, 0)
+		if c.batchURL != "" {
+			atomic.StoreInt32(&calls, 1)
+		}
+		svc, _ := mkNote(&NoteConfig{Config: Config{Addr: srv.URL}, NoteURL: "/note", BatchURL: c.batchURL})
+		n, err := svc.(*Note).Batch("x", items, nil)
+		if err == nil || n != c.want {
+			t.Errorf("%s: got %v,%v; want %v,%v", c.name, n, err, c.want, "error")
+		}
+	}
+}
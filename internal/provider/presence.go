@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/BethesdaNet/friends-go/internal/client"
 	"github.com/BethesdaNet/friends-go/internal/platform"
 )
 
@@ -25,7 +26,8 @@ type PresenceConfig struct {
 // Close method will be called during teardown
 func (p *Presence) Close() {}
 
-// Check validates the key and returns the buid of the caller
+// Check validates the key and returns the buid of the caller. Results are not
+// cached so a revoked key is rejected on its next use.
 func (p *Presence) Check(key, product string, out interface{}) error {
 	ctx, cancel := context.WithTimeout(context.Background(), p.Timeout)
 	defer cancel()
@@ -34,8 +36,8 @@ func (p *Presence) Check(key, product string, out interface{}) error {
 
 func (p *Presence) check(ctx context.Context, key, product string) *http.Request {
 	const bodyfmt = `{"system_name":%q,"system_key":%q}`
-	r, _ := http.NewRequest(http.MethodPost, p.Addr+p.PresenceURL, strings.NewReader(fmt.Sprintf(bodyfmt, key, product)))
+	r, _ := http.NewRequest(http.MethodPost, p.PresenceURL, strings.NewReader(fmt.Sprintf(bodyfmt, key, product)))
 	r.Header.Set(platform.HeaderKeyServer, p.Key)
 	r.Header.Set("Content-Type", DefaultContentType)
-	return r.WithContext(ctx)
+	return client.Retryable(r.WithContext(ctx))
 }
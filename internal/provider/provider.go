@@ -2,6 +2,8 @@ package provider
 
 import (
 	"net/http"
+
+	"github.com/BethesdaNet/friends-go/internal/client"
 )
 
 // Open returns a service provider based on the provided config
@@ -25,6 +27,7 @@ func Open(i interface{}) (Service, error) {
 
 // Service interface to provide control over provider struct
 type Service interface {
+	Client() Client
 	SetClient(Client) error
 	Close()
 }
@@ -37,6 +40,20 @@ type Client interface {
 // Provider used as base embedded struct to broker calls to bnet services
 type provider struct {
 	client Client
+	cache  client.Cache
+}
+
+// Stats returns the lookup cache counters if the provider cache is enabled
+func (p *provider) Stats() (client.CacheStats, bool) {
+	if c, ok := p.cache.(*client.LRU); ok {
+		return c.Stats(), true
+	}
+	return client.CacheStats{}, false
+}
+
+// Client returns the client used to reach the provider
+func (p *provider) Client() Client {
+	return p.client
 }
 
 func (p *provider) SetClient(c Client) error {